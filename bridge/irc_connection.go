@@ -49,7 +49,7 @@ func (i *ircConnection) Connected() bool {
 
 func (i *ircConnection) OnWelcome(e *irc.Event) {
 	// execute puppet prejoin commands
-	err := i.manager.varys.SendRaw(i.discord.ID, varys.InterpolationParams{Nick: true}, i.manager.bridge.Config.IRCPuppetPrejoinCommands...)
+	_, err := i.manager.varys.SendRaw(i.discord.ID, varys.InterpolationParams{Nick: true}, i.manager.bridge.Config.IRCPuppetPrejoinCommands...)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -61,11 +61,11 @@ func (i *ircConnection) OnWelcome(e *irc.Event) {
 
 	go func(i *ircConnection) {
 		for m := range i.messages {
-			msg := m.Message
 			if m.IsAction {
-				msg = fmt.Sprintf("\001ACTION %s\001", msg)
+				i.SendAction(m.IRCChannel, m.Message)
+				continue
 			}
-			i.Privmsg(m.IRCChannel, msg)
+			i.Privmsg(m.IRCChannel, m.Message)
 		}
 	}(i)
 }
@@ -166,15 +166,25 @@ func (i *ircConnection) OnPrivateMessage(e *irc.Event) {
 }
 
 func (i *ircConnection) SendRaw(message string) {
-	if err := i.manager.varys.SendRaw(i.discord.ID, varys.InterpolationParams{}, message); err != nil {
+	if _, err := i.manager.varys.SendRaw(i.discord.ID, varys.InterpolationParams{}, message); err != nil {
 		panic(err.Error())
 	}
 }
 
+func (i *ircConnection) SendAction(target, message string) {
+	if err := i.manager.varys.SendAction(i.discord.ID, target, message); err != nil {
+		log.Warnln("Could not send action", i.discord, target, err)
+	}
+}
+
 func (i *ircConnection) SetAway(status string) {
-	i.SendRaw(fmt.Sprintf("AWAY :%s", status))
+	if err := i.manager.varys.SetAway(i.discord.ID, status); err != nil {
+		log.Warnln("Could not set away status", i.discord, err)
+	}
 }
 
 func (i *ircConnection) Privmsg(target, message string) {
-	i.SendRaw(fmt.Sprintf("PRIVMSG %s :%s\r\n", target, message))
+	if err := i.manager.varys.SendMessage(i.discord.ID, target, message); err != nil {
+		log.Warnln("Could not send message", i.discord, target, err)
+	}
 }
@@ -37,7 +37,7 @@ func newIRCManager(bridge *Bridge) (*IRCManager, error) {
 	}
 
 	// Set up varys
-	m.varys = varys.NewMemClient()
+	m.varys = varys.NewMemClient(log.StandardLogger())
 	err := m.varys.Setup(varys.SetupParams{
 		UseTLS:             !conf.NoTLS,
 		InsecureSkipVerify: conf.InsecureSkipVerify,
@@ -45,6 +45,9 @@ func newIRCManager(bridge *Bridge) (*IRCManager, error) {
 		Server:         conf.IRCServer,
 		ServerPassword: conf.IRCServerPass,
 		WebIRCPassword: conf.WebIRCPass,
+
+		NickSuffix:    conf.Suffix,
+		MaxNickLength: conf.MaxNickLength,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to set up params: %w", err)
@@ -256,7 +259,10 @@ func (m *IRCManager) HandleUser(user DiscordUser) {
 		Username: username,
 		RealName: user.Username,
 
-		WebIRCSuffix: fmt.Sprintf("discord %s %s", hostname, ip),
+		WebIRC: varys.WebIRC{
+			Hostname: hostname,
+			IP:       ip,
+		},
 
 		Callbacks: map[string]func(*irc.Event){
 			"001":     con.OnWelcome,
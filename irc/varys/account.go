@@ -0,0 +1,61 @@
+package varys
+
+import (
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// accountCaps are the IRCv3 capabilities that tell us users' services accounts, requested when available
+var accountCaps = []string{"account-notify", "extended-join"}
+
+// trackAccount keeps track of the services account we are logged in to.
+func (c *connection) trackAccount(conn *irc.Connection) {
+	// RPL_LOGGEDIN <nick> <nick!user@host> <account> :You are now logged in as <account>
+	conn.AddCallback("900", func(e *irc.Event) {
+		if len(e.Arguments) >= 3 {
+			c.mu.Lock()
+			c.account = e.Arguments[2]
+			c.mu.Unlock()
+		}
+	})
+
+	// RPL_LOGGEDOUT
+	conn.AddCallback("901", func(e *irc.Event) {
+		c.mu.Lock()
+		c.account = ""
+		c.mu.Unlock()
+	})
+}
+
+// currentAccount returns the services account we are logged in to, or a blank string if we aren't.
+func (c *connection) currentAccount() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.account
+}
+
+// eventAccount returns the services account of the user who caused e,
+// or a blank string if they aren't logged in or the server didn't say.
+func eventAccount(conn *irc.Connection, e *irc.Event) string {
+	var account string
+	switch {
+	case e.Code == "JOIN" && hasCap(conn, "extended-join"):
+		// JOIN <channel> <account> :<realname>
+		if len(e.Arguments) >= 2 {
+			account = e.Arguments[1]
+		}
+	case e.Code == "ACCOUNT" && hasCap(conn, "account-notify"):
+		// ACCOUNT <account>
+		if len(e.Arguments) >= 1 {
+			account = e.Arguments[0]
+		}
+	default:
+		// From the account-tag capability, if another client requested it
+		account = e.Tags["account"]
+	}
+
+	// Users who aren't logged in have the account "*"
+	if account == "*" {
+		return ""
+	}
+	return account
+}
@@ -0,0 +1,24 @@
+package varys
+
+import (
+	"testing"
+
+	irc "github.com/qaisjp/go-ircevent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventAccount(t *testing.T) {
+	conn := irc.IRC("qais~d", "qais")
+	join := &irc.Event{Code: "JOIN", Arguments: []string{"#channel", "qaisjp", "Qais"}}
+	account := &irc.Event{Code: "ACCOUNT", Arguments: []string{"*"}}
+
+	// Without the capabilities, the arguments mean something else
+	assert.Equal(t, "", eventAccount(conn, join))
+
+	conn.AcknowledgedCaps = accountCaps
+	assert.Equal(t, "qaisjp", eventAccount(conn, join))
+	assert.Equal(t, "", eventAccount(conn, account))
+
+	privmsg := &irc.Event{Code: "PRIVMSG", Tags: map[string]string{"account": "qaisjp"}}
+	assert.Equal(t, "qaisjp", eventAccount(conn, privmsg))
+}
@@ -0,0 +1,39 @@
+package varys
+
+import (
+	"strings"
+)
+
+// ctcpQuote strips characters that would break CTCP framing, or the IRC line itself.
+var ctcpQuote = strings.NewReplacer("\x01", "", "\r", "", "\n", " ")
+
+type SendActionParams struct {
+	UID    string
+	Target string
+	Text   string
+}
+
+// SendAction sends a CTCP ACTION, i.e. a /me, to the target.
+//
+// It's split and rate limited just like SendRaw.
+func (v *Varys) SendAction(params SendActionParams, _ *struct{}) error {
+	msg, err := formatMessage("PRIVMSG", params.Target, "\x01ACTION "+ctcpQuote.Replace(params.Text)+"\x01")
+	if err != nil {
+		return err
+	}
+
+	if err := v.activate(params.UID); err != nil {
+		return err
+	}
+
+	found := false
+	v.connCall(params.UID, func(c *connection) {
+		found = true
+		c.sendSplit(msg, nil)
+		metricMessagesSent.Inc()
+	})
+	if !found {
+		return errNotConnected
+	}
+	return nil
+}
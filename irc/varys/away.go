@@ -0,0 +1,50 @@
+package varys
+
+import (
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+func awayCommand(message string) string {
+	if message == "" {
+		return "AWAY"
+	}
+	return "AWAY :" + lineQuote.Replace(message)
+}
+
+// restoreAway marks us as away again once conn has registered, if we were away before.
+func (c *connection) restoreAway(conn *irc.Connection) {
+	conn.AddCallback("001", func(e *irc.Event) {
+		c.mu.Lock()
+		away := c.away
+		c.mu.Unlock()
+
+		if away != "" {
+			conn.SendRaw(awayCommand(away))
+		}
+	})
+}
+
+type SetAwayParams struct {
+	UID     string
+	Message string
+}
+
+// SetAway marks the connection as away. A blank message marks it as back.
+//
+// The away message is restored after reconnecting.
+func (v *Varys) SetAway(params SetAwayParams, _ *struct{}) error {
+	found := false
+	v.connCall(params.UID, func(c *connection) {
+		found = true
+
+		c.mu.Lock()
+		c.away = params.Message
+		c.mu.Unlock()
+
+		c.send(awayCommand(params.Message))
+	})
+	if !found {
+		return errNotConnected
+	}
+	return nil
+}
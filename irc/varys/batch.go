@@ -0,0 +1,69 @@
+package varys
+
+import "sync"
+
+// defaultConnectParallelism is how many connections ConnectBatch establishes at once by default
+const defaultConnectParallelism = 8
+
+type ConnectBatchParams struct {
+	Connections []ConnectParams
+
+	// Parallelism is how many connections are established at once. Defaults to 8.
+	Parallelism int
+}
+
+func (p ConnectBatchParams) parallelism() int {
+	if p.Parallelism <= 0 {
+		return defaultConnectParallelism
+	}
+	return p.Parallelism
+}
+
+// ConnectResult is the outcome of connecting one UID in a batch
+type ConnectResult struct {
+	UID string
+
+	// Error is blank if the connection was established
+	Error string
+}
+
+// ConnectBatch establishes many connections concurrently, as if by Connect.
+//
+// Results are in the same order as the connections. An error connecting one UID,
+// such as because it is already connected, does not stop the others from connecting.
+func (v *Varys) ConnectBatch(params ConnectBatchParams, result *[]ConnectResult) error {
+	conns := make([]*connection, len(params.Connections))
+	for i, conn := range params.Connections {
+		v.forgetIdle(conn.key())
+		conns[i] = newConnection(conn, v.config().RateLimit)
+	}
+
+	*result = v.connectAll(conns, params.parallelism())
+	return nil
+}
+
+// connectAll connects conns, at most parallelism at a time.
+func (v *Varys) connectAll(conns []*connection, parallelism int) []ConnectResult {
+	results := make([]ConnectResult, len(conns))
+	slots := make(chan struct{}, parallelism)
+
+	var wg sync.WaitGroup
+	for i, c := range conns {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, c *connection) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			results[i].UID = c.params.key()
+			if err := v.connect(c); err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	return results
+}
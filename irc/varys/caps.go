@@ -0,0 +1,119 @@
+package varys
+
+import (
+	"sort"
+	"strings"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// defaultCaps are the IRCv3 capabilities we request by default
+var defaultCaps = func() []string {
+	caps := append([]string(nil), tagCaps...)
+	caps = append(caps, "echo-message")
+	caps = append(caps, accountCaps...)
+	caps = append(caps, "chghost")
+	return append(caps, multilineCaps...)
+}()
+
+// requestCaps returns the capabilities to request: RequestCaps if set, otherwise defaultCaps.
+// sasl is left to the SASL settings, and duplicates are removed.
+func (p SetupParams) requestCaps() []string {
+	if p.RequestCaps == nil {
+		return defaultCaps
+	}
+
+	caps := make([]string, 0, len(p.RequestCaps))
+	seen := make(map[string]bool)
+	for _, name := range p.RequestCaps {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "sasl" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		caps = append(caps, name)
+	}
+	return caps
+}
+
+// enabledCaps returns the capabilities the server acknowledged on conn, sorted by name.
+func enabledCaps(conn *irc.Connection) []string {
+	caps := append([]string(nil), conn.AcknowledgedCaps...)
+	sort.Strings(caps)
+	return caps
+}
+
+// parseCapLS parses a CAP LS reply, returning the capabilities it lists with their values,
+// and whether more lines are to come. Values are only sent in reply to CAP LS 302.
+//
+// CAP <me> LS [*] :<cap>[=<value>]...
+func parseCapLS(args []string) (caps map[string]string, more bool) {
+	if len(args) < 3 || args[1] != "LS" {
+		return nil, false
+	}
+
+	caps = make(map[string]string)
+	for _, token := range strings.Fields(args[len(args)-1]) {
+		name, value := token, ""
+		if i := strings.IndexByte(token, '='); i != -1 {
+			name, value = token[:i], token[i+1:]
+		}
+		caps[name] = value
+	}
+	return caps, len(args) >= 4 && args[2] == "*"
+}
+
+// trackCaps remembers the capabilities the server advertised, and those it enabled once registration has finished.
+func (c *connection) trackCaps(conn *irc.Connection) {
+	conn.AddCallback("CAP", func(e *irc.Event) {
+		caps, _ := parseCapLS(e.Arguments)
+		if caps == nil {
+			return
+		}
+
+		c.mu.Lock()
+		for name, value := range caps {
+			c.advertised[name] = value
+		}
+		c.mu.Unlock()
+	})
+
+	conn.AddCallback("001", func(e *irc.Event) {
+		caps := enabledCaps(conn)
+
+		c.mu.Lock()
+		c.caps = caps
+		c.mu.Unlock()
+	})
+}
+
+// currentCaps returns the capabilities enabled on the current connection, sorted by name.
+func (c *connection) currentCaps() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.caps...)
+}
+
+// GetCaps returns the IRCv3 capabilities enabled for uid, with the values the server advertised them with,
+// such as "PLAIN,EXTERNAL" for sasl. They are blank until the connection has registered.
+//
+// go-ircevent asks for CAP LS without version 302, so most servers don't advertise values, and they are blank.
+func (v *Varys) GetCaps(uid string, result *map[string]string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	c, ok := v.uidToConns[uid]
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	caps := make(map[string]string, len(c.caps))
+	for _, name := range c.caps {
+		caps[name] = c.advertised[name]
+	}
+	c.mu.Unlock()
+
+	*result = caps
+	return nil
+}
@@ -0,0 +1,30 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCapLS(t *testing.T) {
+	caps, more := parseCapLS([]string{"*", "LS", "*", "sasl=PLAIN,EXTERNAL multi-prefix"})
+	assert.Equal(t, map[string]string{"sasl": "PLAIN,EXTERNAL", "multi-prefix": ""}, caps)
+	assert.True(t, more)
+
+	caps, more = parseCapLS([]string{"*", "LS", "server-time draft/example=a=b"})
+	assert.Equal(t, map[string]string{"server-time": "", "draft/example": "a=b"}, caps)
+	assert.False(t, more)
+
+	caps, _ = parseCapLS([]string{"nick", "ACK", "sasl"})
+	assert.Nil(t, caps)
+}
+
+func TestRequestCaps(t *testing.T) {
+	assert.Equal(t, defaultCaps, SetupParams{}.requestCaps())
+	assert.Contains(t, defaultCaps, "echo-message")
+
+	caps := SetupParams{RequestCaps: []string{"draft/example", "sasl", "server-time", "draft/example"}}.requestCaps()
+	assert.Equal(t, []string{"draft/example", "server-time"}, caps)
+
+	assert.Empty(t, SetupParams{RequestCaps: []string{}}.requestCaps(), "an empty list disables every capability")
+}
@@ -0,0 +1,303 @@
+package varys
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+type joinedChannel struct {
+	Name string
+	Key  string
+
+	// Joined is true once the server has told us we have joined
+	Joined bool
+
+	// modes are the channel's modes, or nil if we don't know them since joining
+	modes map[string]string
+}
+
+func (ch joinedChannel) joinCommand() string {
+	if ch.Key != "" {
+		return "JOIN " + ch.Name + " " + ch.Key
+	}
+	return "JOIN " + ch.Name
+}
+
+// trackChannels keeps c.channels in sync with the channels conn is in,
+// and rejoins them once conn has registered, or after being kicked.
+func (c *connection) trackChannels(conn *irc.Connection, policy RejoinPolicy) {
+	conn.AddCallback("JOIN", func(e *irc.Event) {
+		if len(e.Arguments) == 0 || !c.isMe(e.Nick) {
+			return
+		}
+		c.mu.Lock()
+		name := strings.ToLower(e.Arguments[0])
+		channel, ok := c.channels[name]
+		if !ok {
+			channel = joinedChannel{Name: e.Arguments[0]}
+		}
+		channel.Joined = true
+		channel.modes = nil
+		c.channels[name] = channel
+		c.mu.Unlock()
+
+		c.releaseChannel(name)
+		c.queries.finish(queryKey("join", e.Arguments[0]), nil)
+	})
+
+	conn.AddCallback("PART", func(e *irc.Event) {
+		if len(e.Arguments) == 0 || !c.isMe(e.Nick) {
+			return
+		}
+		name := strings.ToLower(e.Arguments[0])
+		c.mu.Lock()
+		delete(c.channels, name)
+		c.mu.Unlock()
+
+		c.dropChannel(name, "left the channel whilst rejoining")
+	})
+
+	// On kick, rejoin the channel, unless we keep being kicked
+	conn.AddCallback("KICK", func(e *irc.Event) {
+		if len(e.Arguments) < 2 || !c.isMe(e.Arguments[1]) {
+			return
+		}
+
+		c.mu.Lock()
+		name := strings.ToLower(e.Arguments[0])
+		channel, ok := c.channels[name]
+		if !ok {
+			channel = joinedChannel{Name: e.Arguments[0]}
+		}
+		kicks, delay, rejoin := policy.rejoin(c.kicks[name], time.Now())
+		c.kicks[name] = kicks
+		channel.Joined = false
+		if rejoin {
+			c.channels[name] = channel
+		} else {
+			delete(c.channels, name)
+		}
+		c.mu.Unlock()
+
+		if !rejoin {
+			msg := policy.giveUpMessage(channel.Name)
+			if policy.PartOnGiveUp {
+				c.send("PART " + channel.Name + " :" + msg)
+			}
+			c.dropChannel(name, "gave up rejoining the channel")
+			c.pushVarysEvent(EventRejoinGaveUp, channel.Name, e.Message(), msg)
+			return
+		}
+		time.AfterFunc(delay, func() {
+			c.rejoin(conn, name)
+		})
+	})
+
+	conn.AddCallback("001", func(e *irc.Event) {
+		c.mu.Lock()
+		channels := make([]joinedChannel, 0, len(c.channels))
+		for name, channel := range c.channels {
+			channel.Joined = false
+			c.channels[name] = channel
+			channels = append(channels, channel)
+		}
+		c.mu.Unlock()
+
+		for _, channel := range channels {
+			conn.SendRaw(channel.joinCommand())
+		}
+	})
+}
+
+type JoinParams struct {
+	UID     string
+	Channel string
+	Key     string
+}
+
+// JoinError is returned by Join when the server refuses to let us join a channel
+type JoinError struct {
+	Channel string
+
+	// Code is the numeric the server replied with, such as "474" for being banned
+	Code   string
+	Reason string
+}
+
+func (e *JoinError) Error() string {
+	return fmt.Sprintf("cannot join %s: %s (%s)", e.Channel, e.Reason, e.Code)
+}
+
+// joinErrors are the numerics servers reply with when refusing a JOIN
+var joinErrors = []string{
+	"403", // ERR_NOSUCHCHANNEL
+	"405", // ERR_TOOMANYCHANNELS
+	"471", // ERR_CHANNELISFULL
+	"473", // ERR_INVITEONLYCHAN
+	"474", // ERR_BANNEDFROMCHAN
+	"475", // ERR_BADCHANNELKEY
+	"477", // ERR_NEEDREGGEDNICK
+}
+
+// handleJoinErrors fails pending joins the server refuses.
+func (c *connection) handleJoinErrors(conn *irc.Connection) {
+	for _, code := range joinErrors {
+		conn.AddCallback(code, func(e *irc.Event) {
+			// <me> <channel> :<reason>
+			if len(e.Arguments) < 2 {
+				return
+			}
+			c.joinRefused(strings.ToLower(e.Arguments[1]))
+			c.queries.finish(queryKey("join", e.Arguments[1]), &JoinError{
+				Channel: e.Arguments[1],
+				Code:    e.Code,
+				Reason:  e.Message(),
+			})
+		})
+	}
+}
+
+// joinRefused forgets a channel the server wouldn't let us join, so that we don't rejoin it
+// after reconnecting, and drops the messages held for it.
+//
+// Channels we are in are kept, as some of these numerics are also replies to other commands.
+func (c *connection) joinRefused(name string) {
+	c.mu.Lock()
+	if channel, ok := c.channels[name]; ok && !channel.Joined {
+		delete(c.channels, name)
+	}
+	c.mu.Unlock()
+
+	c.dropChannel(name, "could not rejoin the channel")
+}
+
+// Join joins a channel, unless we have already joined it, and waits for the server to let us in.
+// If the server refuses, a *JoinError is returned.
+//
+// The channel is remembered, and rejoined after reconnecting, unless the server refuses it.
+func (v *Varys) Join(params JoinParams, _ *struct{}) error {
+	if err := validTarget(params.Channel); err != nil {
+		return err
+	}
+	if strings.ContainsAny(params.Key, " ,\r\n\x00") {
+		return errors.New("channel key contains invalid characters")
+	}
+
+	type pending struct {
+		c    *connection
+		wait <-chan *query
+	}
+	var joins []pending
+	var tooLong error
+	key := queryKey("join", params.Channel)
+
+	found := false
+	v.connCall(params.UID, func(c *connection) {
+		found = true
+		if max, ok := c.isupportInt("CHANNELLEN"); ok && len(params.Channel) > max {
+			tooLong = fmt.Errorf("channel name %q is longer than the server allows (%d)", params.Channel, max)
+			return
+		}
+
+		name := strings.ToLower(params.Channel)
+
+		c.mu.Lock()
+		channel, ok := c.channels[name]
+		if !ok {
+			channel = joinedChannel{Name: params.Channel}
+		}
+		channel.Key = params.Key
+		c.channels[name] = channel
+		c.mu.Unlock()
+
+		if channel.Joined {
+			return
+		}
+
+		// While connecting, the JOIN is held until we are welcomed, in case we have
+		// already rejoined our channels. It's harmless if the channel is joined twice.
+		if c.live() == nil {
+			c.send(channel.joinCommand())
+			return
+		}
+
+		joins = append(joins, pending{c, c.queries.start(key, nil, func() {
+			c.send(channel.joinCommand())
+		})})
+	})
+
+	if !found {
+		return errNotConnected
+	}
+
+	err := tooLong
+	for _, join := range joins {
+		if _, joinErr := join.c.queries.wait(key, join.wait, queryTimeout); joinErr != nil && err == nil {
+			err = joinErr
+		}
+	}
+	return err
+}
+
+type PartParams struct {
+	UID     string
+	Channel string
+	Reason  string
+}
+
+// Part leaves a channel, so that it is no longer rejoined after reconnecting.
+func (v *Varys) Part(params PartParams, _ *struct{}) error {
+	if err := validTarget(params.Channel); err != nil {
+		return err
+	}
+
+	found := false
+	v.connCall(params.UID, func(c *connection) {
+		found = true
+
+		name := strings.ToLower(params.Channel)
+		c.mu.Lock()
+		delete(c.channels, name)
+		c.mu.Unlock()
+		c.dropChannel(name, "left the channel whilst rejoining")
+
+		if params.Reason != "" {
+			c.send("PART " + params.Channel + " :" + lineQuote.Replace(params.Reason))
+		} else {
+			c.send("PART " + params.Channel)
+		}
+	})
+	if !found {
+		return errNotConnected
+	}
+	return nil
+}
+
+// GetChannels returns the channels that uid is currently in, sorted by name.
+func (v *Varys) GetChannels(uid string, result *[]string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	c, ok := v.uidToConns[uid]
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	channels := make([]string, 0, len(c.channels))
+	for _, channel := range c.channels {
+		if channel.Joined {
+			channels = append(channels, channel.Name)
+		}
+	}
+	c.mu.Unlock()
+
+	sort.Strings(channels)
+	*result = channels
+	return nil
+}
@@ -0,0 +1,37 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinValidation(t *testing.T) {
+	v := NewVarys(nil)
+
+	assert.Error(t, v.Join(JoinParams{UID: "123", Channel: "#go-nuts\r\nQUIT"}, nil))
+	assert.Error(t, v.Join(JoinParams{UID: "123", Channel: "#a,#b"}, nil))
+	assert.Error(t, v.Join(JoinParams{UID: "123", Channel: "#go-nuts", Key: "a b"}, nil))
+	assert.Error(t, v.Join(JoinParams{UID: "123", Channel: "#go-nuts", Key: "a,b"}, nil))
+	assert.Error(t, v.Join(JoinParams{UID: "123", Channel: "#go-nuts", Key: "a\r\nQUIT"}, nil))
+
+	assert.ErrorIs(t, v.Join(JoinParams{UID: "123", Channel: "#go-nuts", Key: "hunter2"}, nil), errNotConnected)
+}
+
+func TestJoinRefused(t *testing.T) {
+	c := newConnection(ConnectParams{Events: []string{EventMessagesDropped}}, RateLimit{})
+	c.channels["#banned"] = joinedChannel{Name: "#Banned"}
+	c.channels["#joined"] = joinedChannel{Name: "#joined", Joined: true}
+	c.channelPending["#banned"] = []string{"PRIVMSG #Banned :hello"}
+
+	// We stop rejoining channels we were refused, but not ones we are in
+	c.joinRefused("#banned")
+	c.joinRefused("#joined")
+	assert.Len(t, c.channels, 1)
+	assert.Contains(t, c.channels, "#joined")
+
+	events := c.drainEvents()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, []string{"1", "could not rejoin the channel"}, events[0].Arguments)
+	}
+}
@@ -0,0 +1,122 @@
+package varys
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// charset is a legacy single-byte character set, used on networks that aren't UTF-8 clean.
+// A nil charset means UTF-8, and text is passed through untouched.
+type charset struct {
+	name string
+	// high maps the bytes 0x80 to 0xFF to the runes they encode
+	high [128]rune
+}
+
+// cp1252 are the Windows-1252 runes for 0x80 to 0x9F, which are control characters in ISO-8859-1.
+// Undefined bytes map to themselves, as Windows does.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// lookupCharset returns the charset called name, or nil for UTF-8.
+func lookupCharset(name string) (*charset, error) {
+	normalised := strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(name))
+
+	cs := &charset{name: name}
+	for i := range cs.high {
+		cs.high[i] = rune(0x80 + i)
+	}
+
+	switch normalised {
+	case "", "utf8":
+		return nil, nil
+	case "iso88591", "latin1", "l1":
+		return cs, nil
+	case "windows1252", "cp1252":
+		copy(cs.high[:], cp1252[:])
+		return cs, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", name)
+}
+
+// encode converts s from UTF-8, replacing runes the charset can't represent with "?".
+func (cs *charset) encode(s string) string {
+	if cs == nil {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if r < 0x80 {
+			b.WriteByte(byte(r))
+			continue
+		}
+
+		encoded := byte('?')
+		for i, high := range cs.high {
+			if high == r {
+				encoded = byte(0x80 + i)
+				break
+			}
+		}
+		b.WriteByte(encoded)
+	}
+	return b.String()
+}
+
+// decode converts s to UTF-8.
+//
+// Text which is already valid UTF-8 is left alone, as other clients
+// on networks with mixed encodings often send UTF-8 anyway.
+func (cs *charset) decode(s string) string {
+	if cs == nil || utf8.ValidString(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s) * 2)
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x80 {
+			b.WriteByte(s[i])
+		} else {
+			b.WriteRune(cs.high[s[i]-0x80])
+		}
+	}
+	return b.String()
+}
+
+// decodeEvent returns a copy of e with its text converted to UTF-8, including the sender's nick,
+// as servers that allow nicks outside ASCII use the same charset for them. Tags are always UTF-8.
+func (cs *charset) decodeEvent(e *irc.Event) *irc.Event {
+	if cs == nil {
+		return e
+	}
+
+	decoded := *e
+	decoded.Raw = cs.decode(e.Raw)
+	decoded.Nick = cs.decode(e.Nick)
+	decoded.User = cs.decode(e.User)
+	decoded.Host = cs.decode(e.Host)
+	decoded.Source = cs.decode(e.Source)
+	decoded.Arguments = make([]string, len(e.Arguments))
+	for i, arg := range e.Arguments {
+		decoded.Arguments[i] = cs.decode(arg)
+	}
+	return &decoded
+}
+
+// encoding returns the charset to use on this connection, which is nil if the server is UTF-8 only.
+func (c *connection) encoding() *charset {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.supports("UTF8ONLY") {
+		return nil
+	}
+	return c.charset
+}
@@ -0,0 +1,61 @@
+package varys
+
+import (
+	"testing"
+
+	irc "github.com/qaisjp/go-ircevent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupCharset(t *testing.T) {
+	for _, name := range []string{"", "UTF-8", "utf8"} {
+		cs, err := lookupCharset(name)
+		assert.NoError(t, err, name)
+		assert.Nil(t, cs, name)
+	}
+
+	for _, name := range []string{"ISO-8859-1", "latin1", "Windows-1252", "CP1252"} {
+		cs, err := lookupCharset(name)
+		assert.NoError(t, err, name)
+		assert.NotNil(t, cs, name)
+	}
+
+	_, err := lookupCharset("EBCDIC")
+	assert.Error(t, err)
+}
+
+func TestCharset(t *testing.T) {
+	latin1, _ := lookupCharset("ISO-8859-1")
+	assert.Equal(t, "caf\xe9 ?", latin1.encode("café €"))
+	assert.Equal(t, "café", latin1.decode("caf\xe9"))
+	assert.Equal(t, "café €", latin1.decode("café €"), "valid UTF-8 should be left alone")
+
+	cp1252, _ := lookupCharset("windows-1252")
+	assert.Equal(t, "caf\xe9 \x80", cp1252.encode("café €"))
+	assert.Equal(t, "“quoted”", cp1252.decode("\x93quoted\x94"))
+
+	var utf8 *charset
+	assert.Equal(t, "café €", utf8.encode("café €"))
+	assert.Equal(t, "caf\xe9", utf8.decode("caf\xe9"))
+}
+
+func TestCharsetDecodeEvent(t *testing.T) {
+	latin1, _ := lookupCharset("latin1")
+	e := &irc.Event{
+		Raw:       ":j\xfcrgen!j\xfcrgen@host PRIVMSG #b :caf\xe9",
+		Nick:      "j\xfcrgen",
+		User:      "j\xfcrgen",
+		Host:      "host",
+		Source:    "j\xfcrgen!j\xfcrgen@host",
+		Arguments: []string{"#b", "caf\xe9"},
+	}
+
+	decoded := latin1.decodeEvent(e)
+	assert.Equal(t, ":jürgen!jürgen@host PRIVMSG #b :café", decoded.Raw)
+	assert.Equal(t, "jürgen", decoded.Nick)
+	assert.Equal(t, "jürgen", decoded.User)
+	assert.Equal(t, "host", decoded.Host)
+	assert.Equal(t, "jürgen!jürgen@host", decoded.Source)
+	assert.Equal(t, []string{"#b", "café"}, decoded.Arguments)
+	assert.Equal(t, "caf\xe9", e.Arguments[1], "the original event should not change")
+}
@@ -1,12 +1,15 @@
 package varys
 
+import "github.com/sirupsen/logrus"
+
 type memClient struct {
 	varys *Varys
 }
 
-// NewMemClient returns an in-memory variant of varys
-func NewMemClient() Client {
-	return &memClient{varys: NewVarys()}
+// NewMemClient returns an in-memory variant of varys, which logs to logger.
+// If logger is nil, logrus's standard logger is used.
+func NewMemClient(logger *logrus.Logger) Client {
+	return &memClient{varys: NewVarys(logger)}
 }
 
 func (c *memClient) Setup(params SetupParams) error {
@@ -22,18 +25,160 @@ func (c *memClient) Connect(params ConnectParams) error {
 	return c.varys.Connect(params, nil)
 }
 
+func (c *memClient) ConnectBatch(connections []ConnectParams, parallelism int) (result []ConnectResult, err error) {
+	err = c.varys.ConnectBatch(ConnectBatchParams{connections, parallelism}, &result)
+	return
+}
+
+func (c *memClient) SaveSnapshot(path string) error {
+	return c.varys.SaveSnapshot(path, nil)
+}
+
+func (c *memClient) Restore(path string) (result []ConnectResult, err error) {
+	err = c.varys.Restore(path, &result)
+	return
+}
+
 func (c *memClient) QuitIfConnected(uid string, quitMessage string) error {
-	return c.varys.QuitIfConnected(QuitParams{uid, quitMessage}, nil)
+	return c.varys.QuitIfConnected(QuitParams{uid, quitMessage, false}, nil)
+}
+
+func (c *memClient) ForceQuit(uid string, quitMessage string) error {
+	return c.varys.QuitIfConnected(QuitParams{uid, quitMessage, true}, nil)
+}
+
+func (c *memClient) QuitAll(quitMessage string) error {
+	return c.varys.QuitAll(quitMessage, nil)
+}
+
+func (c *memClient) SendRaw(uid string, params InterpolationParams, messages ...string) (result SendRawResult, err error) {
+	err = c.varys.SendRaw(SendRawParams{uid, messages, params, nil}, &result)
+	return
+}
+
+func (c *memClient) SendTagged(uid string, tags map[string]string, messages ...string) (result SendRawResult, err error) {
+	err = c.varys.SendRaw(SendRawParams{UID: uid, Messages: messages, Tags: tags}, &result)
+	return
+}
+
+func (c *memClient) SendMulti(uid string, targets []string, text string) (result []SendResult, err error) {
+	err = c.varys.SendMulti(SendMultiParams{uid, targets, text}, &result)
+	return
+}
+
+func (c *memClient) SendLines(uid string, target string, lines []string) error {
+	return c.varys.SendLines(SendLinesParams{uid, target, lines}, nil)
 }
 
-func (c *memClient) SendRaw(uid string, params InterpolationParams, messages ...string) error {
-	return c.varys.SendRaw(SendRawParams{uid, messages, params}, nil)
+func (c *memClient) SendMessage(uid string, target string, text string) error {
+	return c.varys.SendMessage(SendMessageParams{uid, target, text}, nil)
+}
+
+func (c *memClient) SendNotice(uid string, target string, text string) error {
+	return c.varys.SendNotice(SendMessageParams{uid, target, text}, nil)
+}
+
+func (c *memClient) SendAction(uid string, target string, text string) error {
+	return c.varys.SendAction(SendActionParams{uid, target, text}, nil)
 }
 
 func (c *memClient) Nick(uid string, nick string) error {
 	return c.varys.Nick(NickParams{uid, nick}, nil)
 }
 
+func (c *memClient) Join(uid string, channel string, key string) error {
+	return c.varys.Join(JoinParams{uid, channel, key}, nil)
+}
+
+func (c *memClient) Part(uid string, channel string, reason string) error {
+	return c.varys.Part(PartParams{uid, channel, reason}, nil)
+}
+
+func (c *memClient) GetQueueDepth(uid string) (result int, err error) {
+	err = c.varys.GetQueueDepth(uid, &result)
+	return
+}
+
+func (c *memClient) FlushQueue(uid string, drop bool) (result int, err error) {
+	err = c.varys.FlushQueue(FlushQueueParams{uid, drop}, &result)
+	return
+}
+
+func (c *memClient) SetDebug(uid string, enabled bool) error {
+	return c.varys.SetDebug(SetDebugParams{uid, enabled}, nil)
+}
+
+func (c *memClient) Reauth(uid string) error {
+	return c.varys.Reauth(uid, nil)
+}
+
+func (c *memClient) SetAway(uid string, message string) error {
+	return c.varys.SetAway(SetAwayParams{uid, message}, nil)
+}
+
+func (c *memClient) Whois(uid string, target string) (result WhoisResult, err error) {
+	err = c.varys.Whois(WhoisParams{uid, target}, &result)
+	return
+}
+
+func (c *memClient) Kick(uid string, channel string, target string, reason string) error {
+	return c.varys.Kick(KickParams{uid, channel, target, reason}, nil)
+}
+
+func (c *memClient) SetBan(uid string, channel string, masks ...string) error {
+	return c.varys.SetBan(BanParams{UID: uid, Channel: channel, Masks: masks}, nil)
+}
+
+func (c *memClient) RemoveBan(uid string, channel string, masks ...string) error {
+	return c.varys.RemoveBan(BanParams{UID: uid, Channel: channel, Masks: masks}, nil)
+}
+
+func (c *memClient) ServiceQuery(uid string, service string, command string) (result []string, err error) {
+	err = c.varys.ServiceQuery(ServiceQueryParams{UID: uid, Service: service, Command: command}, &result)
+	return
+}
+
+func (c *memClient) Who(uid string, mask string) (result []WhoRow, err error) {
+	err = c.varys.Who(WhoParams{uid, mask}, &result)
+	return
+}
+
+func (c *memClient) Names(uid string, channel string) (result []ChannelMember, err error) {
+	err = c.varys.Names(NamesParams{uid, channel}, &result)
+	return
+}
+
+func (c *memClient) GetTopic(uid string, channel string) (topic string, err error) {
+	err = c.varys.GetTopic(TopicParams{uid, channel}, &topic)
+	return
+}
+
+func (c *memClient) SetTopic(uid string, channel string, topic string) error {
+	return c.varys.SetTopic(SetTopicParams{uid, channel, topic}, nil)
+}
+
+func (c *memClient) Monitor(uid string, nicks ...string) error {
+	return c.varys.Monitor(MonitorParams{uid, nicks}, nil)
+}
+
+func (c *memClient) Unmonitor(uid string, nicks ...string) error {
+	return c.varys.Unmonitor(MonitorParams{uid, nicks}, nil)
+}
+
+func (c *memClient) TouchActivity(uid string) error {
+	return c.varys.TouchActivity(uid, nil)
+}
+
+func (c *memClient) GetISupport(uid string) (result map[string]string, err error) {
+	err = c.varys.GetISupport(uid, &result)
+	return
+}
+
+func (c *memClient) GetCaps(uid string) (result map[string]string, err error) {
+	err = c.varys.GetCaps(uid, &result)
+	return
+}
+
 func (c *memClient) GetNick(uid string) (result string, err error) {
 	err = c.varys.GetNick(uid, &result)
 	return
@@ -43,3 +188,32 @@ func (c *memClient) Connected(uid string) (result bool, err error) {
 	err = c.varys.Connected(uid, &result)
 	return
 }
+
+func (c *memClient) ResetReconnectCounts(uid string) error {
+	return c.varys.ResetReconnectCounts(uid, nil)
+}
+
+func (c *memClient) Health(primary string) (result HealthResult, err error) {
+	err = c.varys.Health(HealthParams{primary}, &result)
+	return
+}
+
+func (c *memClient) ListConnections() (result []ConnectionInfo, err error) {
+	err = c.varys.ListConnections(struct{}{}, &result)
+	return
+}
+
+func (c *memClient) PollEvents(uid string) (result []Event, err error) {
+	err = c.varys.PollEvents(uid, &result)
+	return
+}
+
+func (c *memClient) GetChannels(uid string) (result []string, err error) {
+	err = c.varys.GetChannels(uid, &result)
+	return
+}
+
+func (c *memClient) GetChannelModes(uid string, channel string) (result map[string]string, err error) {
+	err = c.varys.GetChannelModes(ChannelModesParams{uid, channel}, &result)
+	return
+}
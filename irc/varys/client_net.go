@@ -30,17 +30,72 @@ func (c *netClient) GetUIDToNicks() (result map[string]string, err error) {
 
 func (c *netClient) Connect(params ConnectParams) error {
 	var reply struct{}
-	return c.client.Call("Varys.Connect", params, &reply)
+	return fromRemote(c.client.Call("Varys.Connect", params, &reply))
+}
+
+func (c *netClient) ConnectBatch(connections []ConnectParams, parallelism int) (result []ConnectResult, err error) {
+	err = c.client.Call("Varys.ConnectBatch", ConnectBatchParams{connections, parallelism}, &result)
+	return
+}
+
+func (c *netClient) SaveSnapshot(path string) error {
+	var reply struct{}
+	return c.client.Call("Varys.SaveSnapshot", path, &reply)
+}
+
+func (c *netClient) Restore(path string) (result []ConnectResult, err error) {
+	err = c.client.Call("Varys.Restore", path, &result)
+	return
 }
 
 func (c *netClient) QuitIfConnected(uid string, quitMessage string) error {
 	var reply struct{}
-	return c.client.Call("Varys.QuitIfConnected", QuitParams{uid, quitMessage}, &reply)
+	return c.client.Call("Varys.QuitIfConnected", QuitParams{uid, quitMessage, false}, &reply)
+}
+
+func (c *netClient) ForceQuit(uid string, quitMessage string) error {
+	var reply struct{}
+	return c.client.Call("Varys.QuitIfConnected", QuitParams{uid, quitMessage, true}, &reply)
 }
 
-func (c *netClient) SendRaw(uid string, params InterpolationParams, messages ...string) error {
+func (c *netClient) QuitAll(quitMessage string) error {
 	var reply struct{}
-	return c.client.Call("Varys.SendRaw", SendRawParams{uid, messages, params}, &reply)
+	return c.client.Call("Varys.QuitAll", quitMessage, &reply)
+}
+
+func (c *netClient) SendRaw(uid string, params InterpolationParams, messages ...string) (result SendRawResult, err error) {
+	err = c.client.Call("Varys.SendRaw", SendRawParams{uid, messages, params, nil}, &result)
+	return
+}
+
+func (c *netClient) SendTagged(uid string, tags map[string]string, messages ...string) (result SendRawResult, err error) {
+	err = c.client.Call("Varys.SendRaw", SendRawParams{UID: uid, Messages: messages, Tags: tags}, &result)
+	return
+}
+
+func (c *netClient) SendMulti(uid string, targets []string, text string) (result []SendResult, err error) {
+	err = c.client.Call("Varys.SendMulti", SendMultiParams{uid, targets, text}, &result)
+	return
+}
+
+func (c *netClient) SendLines(uid string, target string, lines []string) error {
+	var reply struct{}
+	return c.client.Call("Varys.SendLines", SendLinesParams{uid, target, lines}, &reply)
+}
+
+func (c *netClient) SendMessage(uid string, target string, text string) error {
+	var reply struct{}
+	return c.client.Call("Varys.SendMessage", SendMessageParams{uid, target, text}, &reply)
+}
+
+func (c *netClient) SendNotice(uid string, target string, text string) error {
+	var reply struct{}
+	return c.client.Call("Varys.SendNotice", SendMessageParams{uid, target, text}, &reply)
+}
+
+func (c *netClient) SendAction(uid string, target string, text string) error {
+	var reply struct{}
+	return c.client.Call("Varys.SendAction", SendActionParams{uid, target, text}, &reply)
 }
 
 func (c *netClient) Nick(uid string, nick string) error {
@@ -48,6 +103,111 @@ func (c *netClient) Nick(uid string, nick string) error {
 	return c.client.Call("Varys.Nick", NickParams{uid, nick}, &reply)
 }
 
+func (c *netClient) Join(uid string, channel string, key string) error {
+	var reply struct{}
+	return c.client.Call("Varys.Join", JoinParams{uid, channel, key}, &reply)
+}
+
+func (c *netClient) Part(uid string, channel string, reason string) error {
+	var reply struct{}
+	return c.client.Call("Varys.Part", PartParams{uid, channel, reason}, &reply)
+}
+
+func (c *netClient) GetQueueDepth(uid string) (result int, err error) {
+	err = c.client.Call("Varys.GetQueueDepth", uid, &result)
+	return
+}
+
+func (c *netClient) FlushQueue(uid string, drop bool) (result int, err error) {
+	err = c.client.Call("Varys.FlushQueue", FlushQueueParams{uid, drop}, &result)
+	return
+}
+
+func (c *netClient) SetDebug(uid string, enabled bool) error {
+	var reply struct{}
+	return c.client.Call("Varys.SetDebug", SetDebugParams{uid, enabled}, &reply)
+}
+
+func (c *netClient) Reauth(uid string) error {
+	var reply struct{}
+	return fromRemote(c.client.Call("Varys.Reauth", uid, &reply))
+}
+
+func (c *netClient) SetAway(uid string, message string) error {
+	var reply struct{}
+	return c.client.Call("Varys.SetAway", SetAwayParams{uid, message}, &reply)
+}
+
+func (c *netClient) Whois(uid string, target string) (result WhoisResult, err error) {
+	err = c.client.Call("Varys.Whois", WhoisParams{uid, target}, &result)
+	return
+}
+
+func (c *netClient) Kick(uid string, channel string, target string, reason string) error {
+	var reply struct{}
+	return c.client.Call("Varys.Kick", KickParams{uid, channel, target, reason}, &reply)
+}
+
+func (c *netClient) SetBan(uid string, channel string, masks ...string) error {
+	var reply struct{}
+	return c.client.Call("Varys.SetBan", BanParams{UID: uid, Channel: channel, Masks: masks}, &reply)
+}
+
+func (c *netClient) RemoveBan(uid string, channel string, masks ...string) error {
+	var reply struct{}
+	return c.client.Call("Varys.RemoveBan", BanParams{UID: uid, Channel: channel, Masks: masks}, &reply)
+}
+
+func (c *netClient) ServiceQuery(uid string, service string, command string) (result []string, err error) {
+	err = c.client.Call("Varys.ServiceQuery", ServiceQueryParams{UID: uid, Service: service, Command: command}, &result)
+	return
+}
+
+func (c *netClient) Who(uid string, mask string) (result []WhoRow, err error) {
+	err = c.client.Call("Varys.Who", WhoParams{uid, mask}, &result)
+	return
+}
+
+func (c *netClient) Names(uid string, channel string) (result []ChannelMember, err error) {
+	err = c.client.Call("Varys.Names", NamesParams{uid, channel}, &result)
+	return
+}
+
+func (c *netClient) GetTopic(uid string, channel string) (topic string, err error) {
+	err = c.client.Call("Varys.GetTopic", TopicParams{uid, channel}, &topic)
+	return
+}
+
+func (c *netClient) SetTopic(uid string, channel string, topic string) error {
+	var reply struct{}
+	return c.client.Call("Varys.SetTopic", SetTopicParams{uid, channel, topic}, &reply)
+}
+
+func (c *netClient) Monitor(uid string, nicks ...string) error {
+	var reply struct{}
+	return c.client.Call("Varys.Monitor", MonitorParams{uid, nicks}, &reply)
+}
+
+func (c *netClient) Unmonitor(uid string, nicks ...string) error {
+	var reply struct{}
+	return c.client.Call("Varys.Unmonitor", MonitorParams{uid, nicks}, &reply)
+}
+
+func (c *netClient) TouchActivity(uid string) error {
+	var reply struct{}
+	return c.client.Call("Varys.TouchActivity", uid, &reply)
+}
+
+func (c *netClient) GetISupport(uid string) (result map[string]string, err error) {
+	err = c.client.Call("Varys.GetISupport", uid, &result)
+	return
+}
+
+func (c *netClient) GetCaps(uid string) (result map[string]string, err error) {
+	err = c.client.Call("Varys.GetCaps", uid, &result)
+	return
+}
+
 func (c *netClient) GetNick(uid string) (result string, err error) {
 	err = c.client.Call("Varys.GetNick", uid, &result)
 	return
@@ -57,3 +217,33 @@ func (c *netClient) Connected(uid string) (result bool, err error) {
 	err = c.client.Call("Varys.GetNick", uid, &result)
 	return
 }
+
+func (c *netClient) ResetReconnectCounts(uid string) error {
+	var reply struct{}
+	return c.client.Call("Varys.ResetReconnectCounts", uid, &reply)
+}
+
+func (c *netClient) Health(primary string) (result HealthResult, err error) {
+	err = c.client.Call("Varys.Health", HealthParams{primary}, &result)
+	return
+}
+
+func (c *netClient) ListConnections() (result []ConnectionInfo, err error) {
+	err = c.client.Call("Varys.ListConnections", struct{}{}, &result)
+	return
+}
+
+func (c *netClient) PollEvents(uid string) (result []Event, err error) {
+	err = c.client.Call("Varys.PollEvents", uid, &result)
+	return
+}
+
+func (c *netClient) GetChannels(uid string) (result []string, err error) {
+	err = c.client.Call("Varys.GetChannels", uid, &result)
+	return
+}
+
+func (c *netClient) GetChannelModes(uid string, channel string) (result map[string]string, err error) {
+	err = c.client.Call("Varys.GetChannelModes", ChannelModesParams{uid, channel}, &result)
+	return
+}
@@ -0,0 +1,312 @@
+package varys
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// Reconnection backoff bounds
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 5 * time.Minute
+
+	// stableConnectionDuration is how long a connection must stay up
+	// before we consider it healthy again, and reset its backoff.
+	stableConnectionDuration = time.Minute
+)
+
+// quitTimeout is how long we wait for the server to close the connection after a QUIT
+const quitTimeout = 5 * time.Second
+
+// drainTimeout is how long we wait for queued messages to be sent before quitting
+const drainTimeout = 5 * time.Second
+
+// drainPollInterval is how often we check whether the queue has been drained
+const drainPollInterval = 50 * time.Millisecond
+
+// errQuit is returned when a connection is established after we've been asked to quit
+var errQuit = errors.New("connection quit whilst connecting")
+
+// connection is a puppet's IRC connection, along with everything needed to re-establish it.
+type connection struct {
+	params ConnectParams
+
+	// done is closed when we are asked to quit, so that we stop reconnecting
+	done chan struct{}
+	// closed is closed once we have stopped reconnecting and the connection has closed
+	closed chan struct{}
+
+	mu   sync.Mutex
+	conn *irc.Connection
+	// reconnecting is true until the server has welcomed us, both when first connecting and when reconnecting
+	reconnecting bool
+	quitMessage  string
+
+	// server is the server we are connected to, from serverIndex in the pool
+	server      string
+	serverIndex int
+
+	// nick is the nick we want, which may differ from the nick we have
+	nick         string
+	nickAttempts int
+
+	// current is the nick the server knows us by
+	current string
+	// host is the host the server shows us with, if we know it
+	host string
+	// realHost is the host we had before being cloaked with RPL_HOSTHIDDEN, if we know it
+	realHost string
+	// account is the services account we are logged in to, if any
+	account string
+	// caps are the capabilities the server enabled, sorted by name
+	caps []string
+	// advertised are the capabilities the server offered in CAP LS, with their values
+	advertised map[string]string
+	// isupport are the features the server advertised in RPL_ISUPPORT
+	isupport map[string]string
+
+	// channels maps lowercased channel names to the channels we are in,
+	// or have asked to join
+	channels map[string]joinedChannel
+
+	// kicks are when we were recently kicked from each channel, by lowercase name.
+	// They are kept after giving up on a channel, so that invites can't get around the limit.
+	kicks map[string][]time.Time
+
+	// topics caches the topics of joined channels, by lowercase name
+	topics map[string]string
+
+	// monitored are the nicks we are watching, by lowercase nick
+	monitored map[string]monitoredNick
+	// isonPending are the nicks asked about by each ISON we are waiting for a reply to
+	isonPending [][]string
+
+	// charset is the legacy charset text is transcoded to, or nil for UTF-8.
+	// It isn't used if the server advertises UTF8ONLY.
+	charset *charset
+
+	// lastSent is the last message sent to each lowercased target, for SetupParams.DedupWindow
+	lastSent map[string]sentMessage
+
+	// connectedAt is when the server last welcomed us
+	connectedAt time.Time
+	// reconnectAttempts and reconnects count our attempts to reconnect, and how many succeeded
+	reconnectAttempts int
+	reconnects        int
+
+	// lastActive is when we last sent something, for quitting idle connections
+	lastActive time.Time
+
+	// debug is non-zero to show go-ircevent's debug output, and is accessed atomically
+	debug int32
+
+	// latency is the last measured round-trip time to the server
+	latency time.Duration
+
+	// away is our away message, restored after reconnecting
+	away string
+
+	// queries are waiting for replies from the server
+	queries queries
+	// serviceQuery is collecting replies from a service, if any.
+	// serviceMu is held for the whole query, so that only one is collected at a time.
+	serviceQuery *serviceQuery
+	serviceMu    sync.Mutex
+
+	// whoQueue are the WHOs we are waiting for replies to, oldest first,
+	// and whoxToken was the token of the last WHOX we sent
+	whoQueue  []whoRequest
+	whoxToken int
+
+	// events are buffered until they are drained by PollEvents
+	events []Event
+
+	// limiter is nil if messages are not rate limited.
+	// Otherwise, messages are queued and sent by sendLoop.
+	limiter *tokenBucket
+	queue   []string
+	queued  chan struct{}
+
+	// pending are messages held whilst reconnecting, and pendingDropped is how many didn't fit
+	pending        []string
+	pendingDropped int
+	// channelPending are held messages to channels, kept until we have rejoined the channel
+	channelPending map[string][]string
+	// sending is true while a message taken from the queue is waiting to be sent
+	sending bool
+}
+
+func newConnection(params ConnectParams, limit RateLimit) *connection {
+	return &connection{
+		params:         params,
+		reconnecting:   true,
+		nick:           params.Nick,
+		current:        params.Nick,
+		lastActive:     time.Now(),
+		done:           make(chan struct{}),
+		closed:         make(chan struct{}),
+		channels:       make(map[string]joinedChannel),
+		channelPending: make(map[string][]string),
+		topics:         make(map[string]string),
+		kicks:          make(map[string][]time.Time),
+		monitored:      make(map[string]monitoredNick),
+		limiter:        newTokenBucket(limit),
+		queued:         make(chan struct{}, 1),
+	}
+}
+
+// irc returns the most recent underlying connection, even if it has dropped.
+func (c *connection) irc() *irc.Connection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
+}
+
+// currentServer returns the server we are connected, or connecting, to.
+func (c *connection) currentServer() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.server
+}
+
+// live returns the underlying connection, or nil if we are connecting or reconnecting.
+func (c *connection) live() *irc.Connection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reconnecting {
+		return nil
+	}
+	return c.conn
+}
+
+// setIRC installs a freshly established connection.
+//
+// If we were asked to quit in the meantime, the new connection is quit and false is returned.
+func (c *connection) setIRC(conn *irc.Connection) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		conn.QuitMessage = c.quitMessage
+		conn.Quit()
+		return false
+	default:
+	}
+
+	c.conn = conn
+	c.reconnecting = false
+	c.connectedAt = time.Now()
+	return true
+}
+
+// quit stops any reconnection attempts and quits the underlying connection, if connected.
+func (c *connection) quit(message string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return
+	default:
+		close(c.done)
+	}
+
+	c.quitMessage = message
+	if c.conn != nil && !c.reconnecting && c.conn.Connected() {
+		c.conn.QuitMessage = message
+		c.conn.Quit()
+	}
+}
+
+// loop waits for the connection to drop, and then reconnects with exponential backoff.
+//
+// Each connection has its own loop, so one flapping connection does not affect the others.
+func (v *Varys) loop(c *connection) {
+	defer close(c.closed)
+	defer c.dropPending()
+
+	var backoff time.Duration
+	for {
+		conn := c.irc()
+		connectedAt := time.Now()
+
+		select {
+		case <-c.done:
+			// Wait for the server to close the connection after our QUIT
+			select {
+			case <-conn.ErrorChan():
+			case <-time.After(quitTimeout):
+			}
+			metricConnected.Dec()
+			return
+		case err := <-conn.ErrorChan():
+			reason := "connection closed"
+			if err != nil {
+				reason = err.Error()
+			}
+			c.pushVarysEvent(EventDisconnected, reason)
+		}
+		metricConnected.Dec()
+
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		c.mu.Lock()
+		c.reconnecting = true
+		for name, channel := range c.channels {
+			channel.Joined = false
+			c.channels[name] = channel
+		}
+		c.mu.Unlock()
+		conn.Disconnect()
+
+		if time.Since(connectedAt) >= stableConnectionDuration {
+			backoff = 0
+		}
+
+		failed := false
+		for {
+			backoff = nextBackoff(backoff)
+
+			select {
+			case <-c.done:
+				return
+			case <-time.After(backoff):
+			}
+
+			metricReconnects.Inc()
+			c.mu.Lock()
+			c.reconnectAttempts++
+			c.mu.Unlock()
+
+			err := v.dial(c, v.pickServer(c, failed))
+			if err == nil {
+				c.mu.Lock()
+				c.reconnects++
+				c.mu.Unlock()
+				break
+			} else if errors.Is(err, errQuit) {
+				return
+			}
+			failed = true
+		}
+	}
+}
+
+// nextBackoff doubles the given backoff, within the reconnection backoff bounds.
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff < minReconnectBackoff {
+		return minReconnectBackoff
+	} else if backoff > maxReconnectBackoff {
+		return maxReconnectBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,62 @@
+package varys
+
+import (
+	"strings"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// defaultCTCPVersion is our reply to CTCP VERSION
+const defaultCTCPVersion = "go-discord-irc (https://github.com/qaisjp/go-discord-irc)"
+
+func (p SetupParams) ctcpVersion() string {
+	if p.CTCPVersion != "" {
+		return p.CTCPVersion
+	}
+	return defaultCTCPVersion
+}
+
+// ctcpReply returns a CTCP reply to target, sent as a NOTICE.
+func ctcpReply(target string, command string, payload string) string {
+	if payload == "" {
+		return "NOTICE " + target + " :\x01" + command + "\x01"
+	}
+	return "NOTICE " + target + " :\x01" + command + " " + ctcpQuote.Replace(payload) + "\x01"
+}
+
+// ctcpPayload returns what follows command in a CTCP message, such as "1234" for "PING 1234".
+// go-ircevent leaves the command in the message of most CTCP events.
+func ctcpPayload(msg string, command string) string {
+	if len(msg) < len(command) || !strings.EqualFold(msg[:len(command)], command) {
+		return msg
+	}
+	return strings.TrimPrefix(msg[len(command):], " ")
+}
+
+// handleCTCP replies to CTCP VERSION, PING and TIME requests.
+//
+// Replies are sent as NOTICEs, as required by the CTCP spec.
+func (v *Varys) handleCTCP(c *connection, conn *irc.Connection) {
+	version := v.config().ctcpVersion()
+	conn.Version = version
+
+	reply := func(target string, command string, payload string) {
+		c.send(ctcpReply(target, command, payload))
+	}
+
+	conn.ClearCallback("CTCP_VERSION")
+	conn.AddCallback("CTCP_VERSION", func(e *irc.Event) {
+		reply(e.Nick, "VERSION", version)
+	})
+
+	conn.ClearCallback("CTCP_PING")
+	conn.AddCallback("CTCP_PING", func(e *irc.Event) {
+		reply(e.Nick, "PING", ctcpPayload(e.Message(), "PING"))
+	})
+
+	conn.ClearCallback("CTCP_TIME")
+	conn.AddCallback("CTCP_TIME", func(e *irc.Event) {
+		reply(e.Nick, "TIME", time.Now().Format(time.RFC1123Z))
+	})
+}
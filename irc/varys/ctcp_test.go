@@ -0,0 +1,17 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCTCPPingReply(t *testing.T) {
+	// go-ircevent gives us "PING <payload>" as the message of CTCP_PING events
+	payload := ctcpPayload("PING 1700000000 123456", "PING")
+	assert.Equal(t, "1700000000 123456", payload)
+	assert.Equal(t, "NOTICE qais :\x01PING 1700000000 123456\x01", ctcpReply("qais", "PING", payload))
+
+	assert.Equal(t, "NOTICE qais :\x01PING\x01", ctcpReply("qais", "PING", ctcpPayload("PING", "PING")))
+	assert.Equal(t, "NOTICE qais :\x01VERSION go-discord irc\x01", ctcpReply("qais", "VERSION", "go-discord\r\nirc\x01"))
+}
@@ -0,0 +1,106 @@
+package varys
+
+import (
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// redactedCommands are the commands with secret parameters, and how many parameters come before them
+var redactedCommands = map[string]int{
+	"PASS":         0,
+	"AUTHENTICATE": 0,
+	"WEBIRC":       0,
+	"OPER":         1,
+}
+
+// redactTraffic hides the passwords in a line sent to or received from the server,
+// such as PASS, AUTHENTICATE and NickServ's IDENTIFY, so that they never reach the logs.
+func redactTraffic(msg string) string {
+	fields := strings.Fields(msg)
+	i := 0
+	if i < len(fields) && strings.HasPrefix(fields[i], "@") {
+		i++ // tags
+	}
+	if i < len(fields) && strings.HasPrefix(fields[i], ":") {
+		i++ // source
+	}
+	if i >= len(fields) {
+		return msg
+	}
+
+	redact := func(keep int) string {
+		return strings.Join(append(fields[:keep:keep], "<redacted>"), " ")
+	}
+	if n, ok := redactedCommands[strings.ToUpper(fields[i])]; ok {
+		if keep := i + 1 + n; len(fields) > keep {
+			return redact(keep)
+		}
+		return msg
+	}
+
+	// Such as "PRIVMSG NickServ :IDENTIFY account password" or "NS IDENTIFY password"
+	for j := i + 1; j < len(fields)-1; j++ {
+		if strings.EqualFold(strings.TrimPrefix(fields[j], ":"), "IDENTIFY") {
+			return redact(j + 1)
+		}
+	}
+	return msg
+}
+
+// logWriter writes go-ircevent's log output to logrus, one entry per line.
+//
+// go-ircevent always has debug output turned on, as it reads Debug without locking.
+// Instead, the lines it only logs for debugging, the ones showing the traffic
+// with the server, are dropped unless debug is set. Passwords in them are redacted.
+type logWriter struct {
+	entry *logrus.Entry
+	debug *int32
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	debug := atomic.LoadInt32(w.debug) != 0
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if strings.HasPrefix(line, "<-- ") || strings.HasPrefix(line, "--> ") {
+			if !debug {
+				continue
+			}
+			line = line[:4] + redactTraffic(line[4:])
+		}
+		w.entry.Infoln(line)
+	}
+	return len(p), nil
+}
+
+// ircLogger returns a logger for go-ircevent that logs to our logger, tagged with the UID.
+func (v *Varys) ircLogger(c *connection) *log.Logger {
+	return log.New(logWriter{v.log.WithField("uid", c.params.key()), &c.debug}, "", 0)
+}
+
+type SetDebugParams struct {
+	UID     string
+	Enabled bool
+}
+
+// SetDebug turns go-ircevent's debug output on or off for the connection.
+// A blank UID sets it for every connection.
+//
+// The setting is kept after reconnecting.
+func (v *Varys) SetDebug(params SetDebugParams, _ *struct{}) error {
+	var enabled int32
+	if params.Enabled {
+		enabled = 1
+	}
+
+	found := false
+	v.connCall(params.UID, func(c *connection) {
+		found = true
+		atomic.StoreInt32(&c.debug, enabled)
+	})
+	if !found && params.UID != "" {
+		return errNotConnected
+	}
+	return nil
+}
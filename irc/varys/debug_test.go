@@ -0,0 +1,44 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactTraffic(t *testing.T) {
+	tests := []struct {
+		msg      string
+		expected string
+	}{
+		{"PASS hunter2", "PASS <redacted>"},
+		{"AUTHENTICATE cWFpcwBxYWlzAGh1bnRlcjI=", "AUTHENTICATE <redacted>"},
+		{"WEBIRC hunter2 varys 1234.user.discord 10.0.0.1", "WEBIRC <redacted>"},
+		{"OPER qais hunter2", "OPER qais <redacted>"},
+		{"PRIVMSG NickServ :IDENTIFY qais hunter2", "PRIVMSG NickServ :IDENTIFY <redacted>"},
+		{"NS IDENTIFY hunter2", "NS IDENTIFY <redacted>"},
+		{"@label=1 PRIVMSG NickServ :identify hunter2", "@label=1 PRIVMSG NickServ :identify <redacted>"},
+		{":qais!q@host PRIVMSG NickServ :IDENTIFY hunter2", ":qais!q@host PRIVMSG NickServ :IDENTIFY <redacted>"},
+
+		// Nothing secret
+		{"AUTHENTICATE", "AUTHENTICATE"},
+		{"PRIVMSG #go-nuts :hello there", "PRIVMSG #go-nuts :hello there"},
+		{"PRIVMSG #go-nuts :IDENTIFY", "PRIVMSG #go-nuts :IDENTIFY"},
+		{"JOIN #go-nuts", "JOIN #go-nuts"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, redactTraffic(test.msg), test.msg)
+	}
+}
+
+func TestSetDebug(t *testing.T) {
+	v := NewVarys(nil)
+	assert.NoError(t, v.SetDebug(SetDebugParams{Enabled: true}, nil), "a blank UID sets every connection, even if there are none")
+	assert.ErrorIs(t, v.SetDebug(SetDebugParams{UID: "123", Enabled: true}, nil), errNotConnected)
+
+	c := newConnection(ConnectParams{UID: "123"}, RateLimit{})
+	v.add(c)
+	assert.NoError(t, v.SetDebug(SetDebugParams{UID: "123", Enabled: true}, nil))
+	assert.Equal(t, int32(1), c.debug)
+}
@@ -0,0 +1,55 @@
+package varys
+
+import (
+	"strings"
+	"time"
+)
+
+// sentMessage is the last message we sent to a target, for deduplicating
+type sentMessage struct {
+	text string
+	at   time.Time
+}
+
+// messageTarget returns the lowercased target of a PRIVMSG or NOTICE, and whether msg is one.
+func messageTarget(msg string) (string, bool) {
+	fields := strings.SplitN(strings.TrimRight(msg, "\r\n"), " ", 3)
+	if len(fields) != 3 {
+		return "", false
+	}
+
+	command := strings.ToUpper(fields[0])
+	if command != "PRIVMSG" && command != "NOTICE" {
+		return "", false
+	}
+	return strings.ToLower(fields[1]), true
+}
+
+// isDuplicate returns whether msg repeats the previous message, sent within window.
+// Only PRIVMSGs and NOTICEs are deduplicated. If perTarget is false,
+// msg is compared with the previous message to any target.
+//
+// Otherwise, msg is remembered as the previous message.
+func (c *connection) isDuplicate(msg string, window time.Duration, perTarget bool, now time.Time) bool {
+	target, ok := messageTarget(msg)
+	if !ok {
+		return false
+	}
+	if !perTarget {
+		target = ""
+	}
+	text := strings.TrimRight(msg, "\r\n")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if last, ok := c.lastSent[target]; ok && last.text == text && now.Sub(last.at) < window {
+		return true
+	}
+
+	if c.lastSent == nil {
+		c.lastSent = make(map[string]sentMessage)
+	}
+	c.lastSent[target] = sentMessage{text: text, at: now}
+	return false
+}
@@ -0,0 +1,31 @@
+package varys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDuplicate(t *testing.T) {
+	c := newConnection(ConnectParams{}, RateLimit{})
+	now := time.Now()
+	window := time.Second
+
+	assert.False(t, c.isDuplicate("PRIVMSG #go-nuts :hello", window, true, now))
+	assert.True(t, c.isDuplicate("PRIVMSG #go-nuts :hello\r\n", window, true, now.Add(time.Millisecond)))
+	assert.False(t, c.isDuplicate("PRIVMSG #go-nuts :hello", window, true, now.Add(2*time.Second)), "the window has passed")
+
+	// Only the immediately preceding message counts
+	assert.False(t, c.isDuplicate("PRIVMSG #go-nuts :bye", window, true, now))
+	assert.False(t, c.isDuplicate("PRIVMSG #go-nuts :hello", window, true, now))
+
+	// Targets are tracked separately, unless perTarget is false
+	assert.False(t, c.isDuplicate("PRIVMSG #general :hello", window, true, now))
+	assert.False(t, c.isDuplicate("PRIVMSG #go-nuts :hello", window, false, now))
+	assert.True(t, c.isDuplicate("PRIVMSG #go-nuts :hello", window, false, now))
+	assert.False(t, c.isDuplicate("PRIVMSG #general :hello", window, false, now), "the whole line is compared")
+
+	assert.False(t, c.isDuplicate("JOIN #go-nuts", window, true, now))
+	assert.False(t, c.isDuplicate("JOIN #go-nuts", window, true, now))
+}
@@ -0,0 +1,16 @@
+package varys
+
+import (
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// isEcho returns true if e is the server echoing back a message we sent,
+// which only happens when the echo-message capability was acknowledged.
+func (c *connection) isEcho(conn *irc.Connection, e *irc.Event) bool {
+	switch e.Code {
+	case "PRIVMSG", "NOTICE", "TAGMSG":
+	default:
+		return false
+	}
+	return c.isMe(e.Nick) && hasCap(conn, "echo-message")
+}
@@ -0,0 +1,138 @@
+package varys
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"strings"
+)
+
+// Reasons Connect can fail, which can be matched with errors.Is.
+// They can also be matched when returned by the net/rpc client.
+var (
+	ErrDNS            = errors.New("could not resolve the server")
+	ErrTLSHandshake   = errors.New("tls handshake failed")
+	ErrSASLFailed     = errors.New("sasl authentication failed")
+	ErrConnectTimeout = errors.New("timed out connecting")
+	ErrNickInUse      = errors.New("nick is in use")
+	ErrRejected       = errors.New("server closed the connection before welcoming us")
+	ErrBanned         = errors.New("banned from the server")
+
+	// ErrAlreadyConnected is returned when connecting a UID that is already connected.
+	// Quit it first to connect it again.
+	ErrAlreadyConnected = errors.New("already connected")
+
+	// ErrTLSNameMismatch is an ErrTLSHandshake where the server's certificate is for another name.
+	// SetupParams.TLSServerName can be used to verify it against the intended name.
+	ErrTLSNameMismatch = fmt.Errorf("%w: certificate does not match the server name", ErrTLSHandshake)
+
+	// ErrTLSPinMismatch is an ErrTLSHandshake where the server's certificate doesn't match SetupParams.TLSPins.
+	ErrTLSPinMismatch = fmt.Errorf("%w: certificate does not match a pinned fingerprint", ErrTLSHandshake)
+
+	// ErrTLSUnsupported is an ErrTLSHandshake where the server didn't reply with TLS at all,
+	// such as when the port only accepts plaintext. This is the only TLS failure we fall back from.
+	ErrTLSUnsupported = fmt.Errorf("%w: server does not speak tls", ErrTLSHandshake)
+)
+
+// remoteErrors can be matched with errors.Is when returned by the net/rpc client
+var remoteErrors = []error{ErrDNS, ErrTLSHandshake, ErrSASLFailed, ErrConnectTimeout, ErrNickInUse, ErrRejected, ErrBanned, ErrAlreadyConnected, ErrReauthUnsupported, ErrTLSNameMismatch, ErrTLSPinMismatch, ErrTLSUnsupported}
+
+// ConnectError is returned by Connect when a connection could not be established
+type ConnectError struct {
+	UID    string
+	Server string
+	Err    error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("could not connect %s to %s: %s", e.UID, e.Server, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// TransportError is returned when every transport we tried failed, such as TLS and then
+// the plaintext fallback. The error of each attempt can be matched with errors.Is.
+type TransportError struct {
+	Attempts []TransportAttempt
+}
+
+// TransportAttempt is one failed attempt to connect with a transport
+type TransportAttempt struct {
+	Transport string // TransportTLS or TransportPlaintext
+	Server    string
+	Err       error
+}
+
+func (e *TransportError) Error() string {
+	reasons := make([]string, len(e.Attempts))
+	for i, attempt := range e.Attempts {
+		reasons[i] = fmt.Sprintf("%s to %s: %s", attempt.Transport, attempt.Server, attempt.Err)
+	}
+	return strings.Join(reasons, "; then ")
+}
+
+func (e *TransportError) Is(target error) bool {
+	for _, attempt := range e.Attempts {
+		if errors.Is(attempt.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyDialError wraps err from opening a connection with the reason it failed, if we can tell.
+//
+// If sasl is set, go-ircevent waits for SASL to finish before returning, and any error
+// that didn't come from the network is the server rejecting us, such as with ERR_SASLFAIL.
+func classifyDialError(err error, sasl bool) error {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("%w: %s", ErrDNS, err)
+	case errors.As(err, &hostnameErr):
+		return fmt.Errorf("%w: %s", ErrTLSNameMismatch, err)
+	case errors.As(err, &recordErr):
+		return fmt.Errorf("%w: %s", ErrTLSUnsupported, err)
+	case errors.As(err, &authorityErr), errors.As(err, &invalidErr):
+		return fmt.Errorf("%w: %s", ErrTLSHandshake, err)
+	case sasl && !errors.As(err, &netErr):
+		return fmt.Errorf("%w: %s", ErrSASLFailed, err)
+	}
+	return err
+}
+
+// remoteError is an error returned over net/rpc, where only its message survives.
+// It matches the remoteErrors that its message mentions.
+type remoteError string
+
+func (e remoteError) Error() string {
+	return string(e)
+}
+
+func (e remoteError) Is(target error) bool {
+	for _, err := range remoteErrors {
+		if target == err {
+			return strings.Contains(string(e), err.Error())
+		}
+	}
+	return false
+}
+
+// fromRemote converts errors returned by the Varys server over net/rpc into remoteErrors.
+func fromRemote(err error) error {
+	if serverErr, ok := err.(rpc.ServerError); ok {
+		return remoteError(serverErr)
+	}
+	return err
+}
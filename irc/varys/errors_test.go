@@ -0,0 +1,60 @@
+package varys
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyDialError(t *testing.T) {
+	err := classifyDialError(&net.OpError{Op: "dial", Err: &net.DNSError{Name: "irc.example.com"}}, false)
+	assert.ErrorIs(t, err, ErrDNS)
+
+	err = classifyDialError(x509.HostnameError{Certificate: &x509.Certificate{DNSNames: []string{"lb.example.com"}}, Host: "irc.example.com"}, false)
+	assert.ErrorIs(t, err, ErrTLSNameMismatch)
+	assert.ErrorIs(t, err, ErrTLSHandshake)
+	assert.False(t, errors.Is(err, ErrTLSUnsupported), "certificate failures must not fall back to plaintext")
+
+	// A plaintext server replying to our handshake
+	err = classifyDialError(tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, false)
+	assert.ErrorIs(t, err, ErrTLSUnsupported)
+	assert.ErrorIs(t, err, ErrTLSHandshake)
+
+	other := errors.New("connection refused")
+	assert.Equal(t, other, classifyDialError(other, false))
+
+	// go-ircevent's own SASL callbacks fail Connect with the server's message
+	err = classifyDialError(errors.New("SASL authentication failed"), true)
+	assert.ErrorIs(t, err, ErrSASLFailed)
+
+	refused := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	assert.Equal(t, refused, classifyDialError(refused, true))
+}
+
+func TestTransportError(t *testing.T) {
+	err := &TransportError{Attempts: []TransportAttempt{
+		{Transport: TransportTLS, Server: "irc.example.com:6697", Err: fmt.Errorf("%w: eof", ErrTLSUnsupported)},
+		{Transport: TransportPlaintext, Server: "irc.example.com:6667", Err: fmt.Errorf("%w: k-lined", ErrBanned)},
+	}}
+	assert.Equal(t, "tls to irc.example.com:6697: tls handshake failed: server does not speak tls: eof; "+
+		"then plaintext to irc.example.com:6667: banned from the server: k-lined", err.Error())
+	assert.ErrorIs(t, err, ErrTLSUnsupported)
+	assert.ErrorIs(t, err, ErrBanned)
+	assert.False(t, errors.Is(err, ErrDNS))
+}
+
+func TestRemoteError(t *testing.T) {
+	err := &ConnectError{UID: "1234", Server: "irc.example.com:6697", Err: fmt.Errorf("%w for %q", ErrSASLFailed, "user")}
+	assert.ErrorIs(t, err, ErrSASLFailed)
+
+	remote := fromRemote(rpc.ServerError(err.Error()))
+	assert.ErrorIs(t, remote, ErrSASLFailed)
+	assert.False(t, errors.Is(remote, ErrNickInUse))
+	assert.Equal(t, err.Error(), remote.Error())
+}
@@ -0,0 +1,143 @@
+package varys
+
+import (
+	"sort"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// maxBufferedEvents is the number of events buffered per connection.
+// Once full, the oldest events are dropped.
+const maxBufferedEvents = 1024
+
+// Event is a serialisable form of irc.Event, so that it can be sent over net/rpc
+type Event struct {
+	UID string
+
+	Code      string
+	Raw       string
+	Nick      string
+	User      string
+	Host      string
+	Source    string
+	Arguments []string
+	Tags      map[string]string
+
+	// Time is when the event happened, from the server-time tag if the server sent one
+	Time time.Time
+
+	// Account is the services account of the user who caused the event, if the server told us.
+	// It is set for JOIN and ACCOUNT events, when the server supports extended-join and account-notify.
+	Account string
+
+	// Echo is true if this is the server echoing back a message we sent.
+	// Echoes are only sent by servers supporting the echo-message capability.
+	Echo bool
+}
+
+func newEvent(uid string, e *irc.Event) Event {
+	return Event{
+		UID: uid,
+
+		Code:      e.Code,
+		Raw:       e.Raw,
+		Nick:      e.Nick,
+		User:      e.User,
+		Host:      e.Host,
+		Source:    e.Source,
+		Arguments: e.Arguments,
+		Tags:      e.Tags,
+		Time:      eventTime(e),
+	}
+}
+
+// Codes of events generated by Varys, rather than received from the server.
+// Like other events, they are only buffered if asked for in ConnectParams.Events.
+const (
+	// EventRejoinGaveUp is sent when we give up rejoining a channel we keep being kicked from.
+	// The arguments are the channel, the last kick reason, and RejoinPolicy.GiveUpMessage.
+	EventRejoinGaveUp = "VARYS_REJOIN_GAVE_UP"
+
+	// EventNickChanged is sent when our nick changes, including when the server forces it to change.
+	// The arguments are the old and new nicks.
+	EventNickChanged = "VARYS_NICK_CHANGED"
+
+	// EventMonitorOnline and EventMonitorOffline are sent when a monitored nick comes online or goes offline.
+	// The argument is the nick.
+	EventMonitorOnline  = "VARYS_MONITOR_ONLINE"
+	EventMonitorOffline = "VARYS_MONITOR_OFFLINE"
+
+	// EventConnected is sent once we have registered with the server, including after reconnecting.
+	// The arguments are our nick, the server, the enabled capabilities separated by spaces,
+	// and our host if we know it yet.
+	EventConnected = "VARYS_CONNECTED"
+
+	// EventHostChanged is sent when the server changes our host, such as when cloaking us.
+	// The argument is the new host.
+	EventHostChanged = "VARYS_HOST_CHANGED"
+
+	// EventDisconnected is sent when the connection drops, before we reconnect.
+	// The argument is why it dropped.
+	EventDisconnected = "VARYS_DISCONNECTED"
+
+	// EventMessagesDropped is sent when messages held whilst reconnecting are dropped,
+	// because too many were held, we quit before reconnecting, or we couldn't rejoin the channel they were for.
+	// The arguments are how many messages were dropped, and why.
+	EventMessagesDropped = "VARYS_MESSAGES_DROPPED"
+)
+
+// pushVarysEvent buffers an event generated by Varys, if the client asked for it.
+func (c *connection) pushVarysEvent(code string, args ...string) {
+	for _, eventcode := range c.params.Events {
+		if eventcode == code || eventcode == "*" {
+			c.pushEvent(Event{UID: c.params.key(), Code: code, Arguments: args, Time: time.Now()})
+			return
+		}
+	}
+}
+
+func (c *connection) pushEvent(e Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.events) >= maxBufferedEvents {
+		c.events = c.events[1:]
+	}
+	c.events = append(c.events, e)
+}
+
+func (c *connection) drainEvents() []Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	events := c.events
+	c.events = nil
+	return events
+}
+
+// PollEvents drains the events buffered for uid, or for all connections if uid is blank.
+func (v *Varys) PollEvents(uid string, result *[]Event) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if uid != "" {
+		if c, ok := v.uidToConns[uid]; ok {
+			*result = c.drainEvents()
+		}
+		return nil
+	}
+
+	uids := make([]string, 0, len(v.uidToConns))
+	for uid := range v.uidToConns {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+
+	var events []Event
+	for _, uid := range uids {
+		events = append(events, v.uidToConns[uid].drainEvents()...)
+	}
+	*result = events
+	return nil
+}
@@ -0,0 +1,50 @@
+package varys
+
+// HealthParams are the parameters for Health
+type HealthParams struct {
+	// Primary is the UID that must be connected for Varys to be ready.
+	// If blank, Varys is ready once any connection is, or when there are none.
+	Primary string
+}
+
+// HealthResult is suitable for liveness and readiness probes
+type HealthResult struct {
+	// Live is always true, as Varys answered. A liveness probe only needs the call to succeed.
+	Live bool
+	// Ready is true once the primary connection is connected and registered
+	Ready bool
+
+	// Connected is how many connections are connected and registered
+	Connected int
+	// Expected is how many connections should be, not counting idle connections
+	Expected int
+}
+
+// registered returns whether c is connected, and has been welcomed by the server.
+func (c *connection) registered() bool {
+	// Connections are only installed once the server has welcomed us
+	conn := c.live()
+	return conn != nil && conn.Connected()
+}
+
+// Health reports whether Varys is responsive, and whether its connections are working.
+func (v *Varys) Health(params HealthParams, result *HealthResult) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	health := HealthResult{Live: true, Expected: len(v.uidToConns)}
+	for _, c := range v.uidToConns {
+		if c.registered() {
+			health.Connected++
+		}
+	}
+
+	if params.Primary == "" {
+		health.Ready = health.Connected > 0 || health.Expected == 0
+	} else if c, ok := v.uidToConns[params.Primary]; ok {
+		health.Ready = c.registered()
+	}
+
+	*result = health
+	return nil
+}
@@ -0,0 +1,22 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	v := NewVarys(nil)
+
+	var health HealthResult
+	assert.NoError(t, v.Health(HealthParams{}, &health))
+	assert.Equal(t, HealthResult{Live: true, Ready: true}, health)
+
+	v.add(newConnection(ConnectParams{UID: "123"}, RateLimit{}))
+	assert.NoError(t, v.Health(HealthParams{}, &health))
+	assert.Equal(t, HealthResult{Live: true, Expected: 1}, health, "nothing is connected yet")
+
+	assert.NoError(t, v.Health(HealthParams{Primary: "456"}, &health))
+	assert.False(t, health.Ready, "the primary connection doesn't exist")
+}
@@ -0,0 +1,95 @@
+package varys
+
+import (
+	"strings"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// welcomeHost returns our host from RPL_WELCOME, if the server included our hostmask in it.
+func welcomeHost(e *irc.Event) string {
+	// 001 <nick> :Welcome to the Internet Relay Network <nick>!<user>@<host>
+	fields := strings.Fields(e.Message())
+	if len(fields) == 0 {
+		return ""
+	}
+	mask := fields[len(fields)-1]
+	if i := strings.LastIndexByte(mask, '@'); i != -1 && strings.Contains(mask[:i], "!") {
+		return mask[i+1:]
+	}
+	return ""
+}
+
+// hiddenHost returns the host from RPL_HOSTHIDDEN, which some servers send as user@host.
+func hiddenHost(e *irc.Event) string {
+	// 396 <nick> <host> :is now your hidden host
+	if len(e.Arguments) < 2 {
+		return ""
+	}
+	host := e.Arguments[1]
+	if i := strings.LastIndexByte(host, '@'); i != -1 {
+		host = host[i+1:]
+	}
+	return host
+}
+
+// trackHost keeps track of the host the server shows us with, from RPL_WELCOME and our JOINs,
+// from RPL_HOSTHIDDEN when we are cloaked, and from CHGHOST when the server supports the chghost capability.
+func (c *connection) trackHost(conn *irc.Connection) {
+	conn.AddCallback("001", func(e *irc.Event) {
+		if host := welcomeHost(e); host != "" {
+			c.mu.Lock()
+			c.host = host
+			c.realHost = host
+			c.mu.Unlock()
+		}
+	})
+
+	conn.AddCallback("396", func(e *irc.Event) {
+		if host := hiddenHost(e); host != "" {
+			c.mu.Lock()
+			// Keep the host we had before being cloaked, for debugging
+			if c.realHost == "" {
+				c.realHost = c.host
+			}
+			c.host = host
+			c.mu.Unlock()
+
+			c.pushVarysEvent(EventHostChanged, host)
+		}
+	})
+
+	conn.AddCallback("JOIN", func(e *irc.Event) {
+		if c.isMe(e.Nick) && e.Host != "" {
+			c.mu.Lock()
+			c.host = e.Host
+			c.mu.Unlock()
+		}
+	})
+
+	// CHGHOST <new user> <new host>
+	conn.AddCallback("CHGHOST", func(e *irc.Event) {
+		if len(e.Arguments) >= 2 && c.isMe(e.Nick) {
+			c.mu.Lock()
+			c.host = e.Arguments[1]
+			c.mu.Unlock()
+
+			c.pushVarysEvent(EventHostChanged, e.Arguments[1])
+		}
+	})
+}
+
+// currentHost returns the host the server shows us with, or a blank string if we don't know it.
+func (c *connection) currentHost() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.host
+}
+
+// currentRealHost returns the host we had before the server cloaked us,
+// or a blank string if we don't know it.
+func (c *connection) currentRealHost() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.realHost
+}
@@ -0,0 +1,24 @@
+package varys
+
+import (
+	"testing"
+
+	irc "github.com/qaisjp/go-ircevent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWelcomeHost(t *testing.T) {
+	e := &irc.Event{Code: "001", Arguments: []string{"qais~d", "Welcome to the Libera.Chat Internet Relay Chat Network qais~d!~qais@203.0.113.7"}}
+	assert.Equal(t, "203.0.113.7", welcomeHost(e))
+
+	e = &irc.Event{Code: "001", Arguments: []string{"qais~d", "Welcome to the network, qais~d"}}
+	assert.Equal(t, "", welcomeHost(e))
+}
+
+func TestHiddenHost(t *testing.T) {
+	e := &irc.Event{Code: "396", Arguments: []string{"qais~d", "user/qais", "is now your hidden host"}}
+	assert.Equal(t, "user/qais", hiddenHost(e))
+
+	e = &irc.Event{Code: "396", Arguments: []string{"qais~d", "~qais@user/qais", "is now your hidden host"}}
+	assert.Equal(t, "user/qais", hiddenHost(e))
+}
@@ -0,0 +1,123 @@
+package varys
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultIdleQuitMessage is used when quitting idle connections, if SetupParams.IdleQuitMessage is blank
+const defaultIdleQuitMessage = "Idle, will return when needed"
+
+// idleConnection is a connection that was quit for being idle, saved so that it can be reconnected on demand.
+type idleConnection struct {
+	saved connectionSnapshot
+
+	// once makes sure concurrent sends only reconnect once
+	once sync.Once
+	err  error
+}
+
+func (p SetupParams) idleQuitMessage() string {
+	if p.IdleQuitMessage == "" {
+		return defaultIdleQuitMessage
+	}
+	return p.IdleQuitMessage
+}
+
+// touch marks c as active, so that it isn't quit for being idle.
+func (c *connection) touch() {
+	c.mu.Lock()
+	c.lastActive = time.Now()
+	c.mu.Unlock()
+}
+
+func (c *connection) idleFor() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Since(c.lastActive)
+}
+
+// watchIdle quits c once it has been idle for timeout, remembering it so that activate can reconnect it.
+//
+// It returns once c has quit.
+func (v *Varys) watchIdle(c *connection, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-timer.C:
+		}
+
+		if idle := c.idleFor(); idle < timeout {
+			timer.Reset(timeout - idle)
+			continue
+		}
+
+		v.mu.Lock()
+		key := c.params.key()
+		if v.uidToConns[key] != c {
+			v.mu.Unlock()
+			return
+		}
+		delete(v.uidToConns, key)
+		v.idle[key] = &idleConnection{saved: c.snapshot()}
+		v.mu.Unlock()
+
+		c.drain(drainTimeout)
+		c.quit(v.quitMessage(c, v.config().idleQuitMessage()))
+		return
+	}
+}
+
+// activate marks the connection for uid as active, reconnecting it if it was quit for being idle.
+// A blank uid does nothing, as sending to every connection isn't activity by any one user.
+func (v *Varys) activate(uid string) error {
+	if uid == "" {
+		return nil
+	}
+
+	v.mu.RLock()
+	c, connected := v.uidToConns[uid]
+	idle := v.idle[uid]
+	v.mu.RUnlock()
+
+	if connected {
+		c.touch()
+		return nil
+	}
+	if idle == nil {
+		return nil
+	}
+
+	idle.once.Do(func() {
+		idle.err = v.connect(v.restoreConnection(idle.saved))
+
+		v.mu.Lock()
+		if v.idle[uid] == idle {
+			if idle.err == nil {
+				delete(v.idle, uid)
+			} else {
+				// Try again next time
+				v.idle[uid] = &idleConnection{saved: idle.saved}
+			}
+		}
+		v.mu.Unlock()
+	})
+	return idle.err
+}
+
+// forgetIdle stops uid from being reconnected on demand.
+func (v *Varys) forgetIdle(uid string) {
+	v.mu.Lock()
+	delete(v.idle, uid)
+	v.mu.Unlock()
+}
+
+// TouchActivity marks uid as active, so that it isn't quit for being idle.
+// If it has already been quit for being idle, it is reconnected.
+func (v *Varys) TouchActivity(uid string, _ *struct{}) error {
+	return v.activate(uid)
+}
@@ -0,0 +1,50 @@
+package varys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchIdle(t *testing.T) {
+	v := NewVarys(nil)
+	c := newConnection(ConnectParams{UID: "uid", Nick: "qais~d"}, RateLimit{})
+	c.channels["#channel"] = joinedChannel{Name: "#channel"}
+	v.uidToConns["uid"] = c
+
+	done := make(chan struct{})
+	go func() {
+		v.watchIdle(c, 50*time.Millisecond)
+		close(done)
+	}()
+
+	// Staying active keeps the connection
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		assert.NoError(t, v.activate("uid"))
+	}
+	v.mu.RLock()
+	assert.Equal(t, c, v.uidToConns["uid"])
+	v.mu.RUnlock()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("connection was not quit for being idle")
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	assert.Empty(t, v.uidToConns)
+	if assert.Contains(t, v.idle, "uid") {
+		assert.Equal(t, "qais~d", v.idle["uid"].saved.Nick)
+		assert.Equal(t, []channelSnapshot{{Name: "#channel"}}, v.idle["uid"].saved.Channels)
+	}
+
+	select {
+	case <-c.done:
+	default:
+		t.Error("idle connection should have quit")
+	}
+}
@@ -0,0 +1,68 @@
+package varys
+
+import (
+	"strings"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// InvitePolicy controls whether we join channels we are invited to
+type InvitePolicy struct {
+	// Accept joins channels we are invited to
+	Accept bool
+
+	// Nicks, if not empty, are the only nicks whose invites are accepted
+	Nicks []string
+	// Channels, if not empty, are the only channels invites are accepted to
+	Channels []string
+}
+
+// allows returns true if we should accept an invite from nick to channel.
+func (p InvitePolicy) allows(nick string, channel string) bool {
+	return p.Accept && (len(p.Nicks) == 0 || containsFold(p.Nicks, nick)) &&
+		(len(p.Channels) == 0 || containsFold(p.Channels, channel))
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleInvites joins channels we are invited to, if the policy allows it.
+//
+// Channels we have given up rejoining after being kicked aren't joined
+// until their kicks are out of the rejoin window.
+func (c *connection) handleInvites(conn *irc.Connection, policy InvitePolicy, rejoin RejoinPolicy) {
+	conn.AddCallback("INVITE", func(e *irc.Event) {
+		// INVITE <nick> <channel>
+		if len(e.Arguments) < 2 || !c.isMe(e.Arguments[0]) {
+			return
+		}
+		channel := e.Arguments[1]
+		if !policy.allows(e.Nick, channel) {
+			return
+		}
+
+		name := strings.ToLower(channel)
+		c.mu.Lock()
+		if len(rejoin.recent(c.kicks[name], time.Now())) >= rejoin.maxAttempts() {
+			c.mu.Unlock()
+			return
+		}
+		ch, ok := c.channels[name]
+		if !ok {
+			ch = joinedChannel{Name: channel}
+			c.channels[name] = ch
+		}
+		c.mu.Unlock()
+
+		if !ch.Joined {
+			conn.SendRaw(ch.joinCommand())
+		}
+	})
+}
@@ -0,0 +1,17 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvitePolicyAllows(t *testing.T) {
+	assert.False(t, InvitePolicy{}.allows("op", "#channel"))
+	assert.True(t, InvitePolicy{Accept: true}.allows("op", "#channel"))
+
+	policy := InvitePolicy{Accept: true, Nicks: []string{"Op"}, Channels: []string{"#Channel"}}
+	assert.True(t, policy.allows("op", "#channel"))
+	assert.False(t, policy.allows("someone", "#channel"))
+	assert.False(t, policy.allows("op", "#elsewhere"))
+}
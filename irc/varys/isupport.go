@@ -0,0 +1,133 @@
+package varys
+
+import (
+	"strconv"
+	"strings"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// parseISupport adds the tokens of an RPL_ISUPPORT reply to tokens.
+// Tokens without a value are stored with a blank value, and negated tokens like "-KEY" are removed.
+func parseISupport(tokens map[string]string, args []string) {
+	for _, token := range args {
+		if strings.HasPrefix(token, "-") {
+			delete(tokens, token[1:])
+			continue
+		}
+
+		key, value := token, ""
+		if i := strings.IndexByte(token, '='); i != -1 {
+			key, value = token[:i], unescapeISupport(token[i+1:])
+		}
+		tokens[key] = value
+	}
+}
+
+// unescapeISupport replaces \xHH escapes in an ISUPPORT value.
+func unescapeISupport(value string) string {
+	if !strings.Contains(value, `\x`) {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+3 < len(value) && value[i+1] == 'x' {
+			if n, err := strconv.ParseUint(value[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// trackISupport remembers the features the server advertises in RPL_ISUPPORT.
+//
+// The previous connection's features are kept until the server advertises
+// its own, so that limits still apply while registering after reconnecting.
+func (c *connection) trackISupport(conn *irc.Connection) {
+	fresh := true
+
+	// RPL_ISUPPORT <me> <token>... :are supported by this server
+	conn.AddCallback("005", func(e *irc.Event) {
+		if len(e.Arguments) < 2 {
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if fresh || c.isupport == nil {
+			c.isupport = make(map[string]string)
+			fresh = false
+		}
+		parseISupport(c.isupport, e.Arguments[1:len(e.Arguments)-1])
+	})
+}
+
+// supports returns true if the server advertised token. c.mu must be held.
+func (c *connection) supports(token string) bool {
+	_, ok := c.isupport[token]
+	return ok
+}
+
+// isupportInt returns the numeric value of an ISUPPORT token, such as NICKLEN.
+func (c *connection) isupportInt(token string) (int, bool) {
+	c.mu.Lock()
+	value, ok := c.isupport[token]
+	c.mu.Unlock()
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// defaultModesLimit is how many mode parameters we send in one MODE if the server doesn't say,
+// which RFC 2811 guarantees
+const defaultModesLimit = 3
+
+// modesLimit returns how many mode parameters the server accepts in one MODE.
+func (c *connection) modesLimit() int {
+	if n, ok := c.isupportInt("MODES"); ok {
+		return n
+	}
+	return defaultModesLimit
+}
+
+// nickLength returns the longest nick we can use: the server's NICKLEN, if shorter than max.
+func (c *connection) nickLength(max int) int {
+	if n, ok := c.isupportInt("NICKLEN"); ok && n < max {
+		return n
+	}
+	return max
+}
+
+// GetISupport returns the features the server advertised to uid in RPL_ISUPPORT.
+// Tokens without a value have a blank value.
+func (v *Varys) GetISupport(uid string, result *map[string]string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	c, ok := v.uidToConns[uid]
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	tokens := make(map[string]string, len(c.isupport))
+	for key, value := range c.isupport {
+		tokens[key] = value
+	}
+	c.mu.Unlock()
+
+	*result = tokens
+	return nil
+}
@@ -0,0 +1,33 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseISupport(t *testing.T) {
+	tokens := map[string]string{"WHOX": ""}
+	parseISupport(tokens, []string{"NICKLEN=30", "UTF8ONLY", "NETWORK=Example\\x20Net", "-WHOX"})
+	assert.Equal(t, map[string]string{
+		"NICKLEN":  "30",
+		"UTF8ONLY": "",
+		"NETWORK":  "Example Net",
+	}, tokens)
+
+	// Later replies override earlier ones
+	parseISupport(tokens, []string{"NICKLEN=16"})
+	assert.Equal(t, "16", tokens["NICKLEN"])
+}
+
+func TestNickLength(t *testing.T) {
+	c := newConnection(ConnectParams{}, RateLimit{})
+	assert.Equal(t, 30, c.nickLength(30))
+
+	c.isupport = map[string]string{"NICKLEN": "16"}
+	assert.Equal(t, 16, c.nickLength(30))
+	assert.Equal(t, 10, c.nickLength(10))
+
+	c.isupport["NICKLEN"] = "bogus"
+	assert.Equal(t, 30, c.nickLength(30))
+}
@@ -0,0 +1,110 @@
+package varys
+
+import (
+	"strings"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+const (
+	defaultRejoinAttempts = 3
+	defaultRejoinWindow   = 10 * time.Minute
+	defaultRejoinBackoff  = time.Second
+
+	defaultGiveUpMessage = "Stopped trying to rejoin ${CHANNEL} after repeated kicks"
+)
+
+// RejoinPolicy controls how we rejoin channels we are kicked from,
+// so that we don't get ourselves banned by rejoining in a loop.
+type RejoinPolicy struct {
+	// Disabled stops us from rejoining channels we are kicked from
+	Disabled bool
+
+	// MaxAttempts is how many times we rejoin a channel within Window,
+	// before giving up on it. Defaults to 3.
+	MaxAttempts int
+
+	// Window defaults to 10 minutes.
+	Window time.Duration
+
+	// Backoff is how long we wait before rejoining,
+	// doubled for each recent kick. Defaults to 1 second.
+	Backoff time.Duration
+
+	// PartOnGiveUp sends a PART with the GiveUpMessage when we give up on a channel,
+	// in case the server has put us back in it.
+	PartOnGiveUp bool
+	// GiveUpMessage describes why we gave up on a channel, with ${CHANNEL} replaced by its name.
+	// It is included in EventRejoinGaveUp. Defaults to "Stopped trying to rejoin ${CHANNEL} after repeated kicks".
+	GiveUpMessage string
+}
+
+func (p RejoinPolicy) giveUpMessage(channel string) string {
+	msg := p.GiveUpMessage
+	if msg == "" {
+		msg = defaultGiveUpMessage
+	}
+	return strings.ReplaceAll(msg, "${CHANNEL}", channel)
+}
+
+func (p RejoinPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return defaultRejoinAttempts
+	}
+	return p.MaxAttempts
+}
+
+func (p RejoinPolicy) window() time.Duration {
+	if p.Window <= 0 {
+		return defaultRejoinWindow
+	}
+	return p.Window
+}
+
+func (p RejoinPolicy) backoff() time.Duration {
+	if p.Backoff <= 0 {
+		return defaultRejoinBackoff
+	}
+	return p.Backoff
+}
+
+// recent returns the kicks within the window.
+func (p RejoinPolicy) recent(kicks []time.Time, now time.Time) []time.Time {
+	recent := make([]time.Time, 0, len(kicks)+1)
+	for _, kick := range kicks {
+		if now.Sub(kick) < p.window() {
+			recent = append(recent, kick)
+		}
+	}
+	return recent
+}
+
+// rejoin records a kick at now, given the times of earlier kicks from the channel.
+//
+// It returns the kicks within the window, and how long to wait before rejoining,
+// or false if we should give up on the channel.
+func (p RejoinPolicy) rejoin(kicks []time.Time, now time.Time) ([]time.Time, time.Duration, bool) {
+	recent := append(p.recent(kicks, now), now)
+
+	if p.Disabled || len(recent) > p.maxAttempts() {
+		return recent, 0, false
+	}
+	return recent, p.backoff() << uint(len(recent)-1), true
+}
+
+// rejoin rejoins a channel we were kicked from, unless it has since been
+// joined, parted or conn has been replaced.
+func (c *connection) rejoin(conn *irc.Connection, name string) {
+	if c.live() != conn {
+		return
+	}
+
+	c.mu.Lock()
+	channel, ok := c.channels[name]
+	c.mu.Unlock()
+
+	if ok && !channel.Joined {
+		conn.SendRaw(channel.joinCommand())
+	}
+}
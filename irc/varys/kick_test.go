@@ -0,0 +1,39 @@
+package varys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejoinPolicy(t *testing.T) {
+	var policy RejoinPolicy
+	now := time.Now()
+
+	// Each recent kick doubles the backoff, until we give up
+	var kicks []time.Time
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		var delay time.Duration
+		var ok bool
+		kicks, delay, ok = policy.rejoin(kicks, now.Add(time.Duration(i)*time.Second))
+		assert.True(t, ok)
+		assert.Equal(t, expected, delay)
+	}
+	_, _, ok := policy.rejoin(kicks, now.Add(time.Minute))
+	assert.False(t, ok)
+
+	// Kicks outside the window are forgotten
+	kicks, delay, ok := policy.rejoin(kicks, now.Add(time.Hour))
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, delay)
+	assert.Len(t, kicks, 1)
+
+	_, _, ok = RejoinPolicy{Disabled: true}.rejoin(nil, now)
+	assert.False(t, ok)
+}
+
+func TestRejoinPolicyGiveUpMessage(t *testing.T) {
+	assert.Equal(t, "Stopped trying to rejoin #go-nuts after repeated kicks", RejoinPolicy{}.giveUpMessage("#go-nuts"))
+	assert.Equal(t, "Giving up on #go-nuts", RejoinPolicy{GiveUpMessage: "Giving up on ${CHANNEL}"}.giveUpMessage("#go-nuts"))
+}
@@ -0,0 +1,47 @@
+package varys
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// latencyToken prefixes the PINGs we send to measure latency,
+// so that their PONGs aren't confused with the library's own.
+const latencyToken = "varys-"
+
+// sendLatencyPing asks the server to PONG the current time back to us.
+//
+// It bypasses the rate limit, as time spent queued isn't network latency.
+func sendLatencyPing(conn *irc.Connection) {
+	conn.SendRaw("PING " + latencyToken + strconv.FormatInt(time.Now().UnixNano(), 10))
+}
+
+// measureLatency records the round-trip time of our PINGs on conn, starting once it has registered.
+func (c *connection) measureLatency(conn *irc.Connection) {
+	conn.AddCallback("001", func(e *irc.Event) {
+		c.mu.Lock()
+		c.latency = 0
+		c.mu.Unlock()
+
+		sendLatencyPing(conn)
+	})
+
+	conn.AddCallback("PONG", func(e *irc.Event) {
+		token := e.Message()
+		if !strings.HasPrefix(token, latencyToken) {
+			return
+		}
+
+		sent, err := strconv.ParseInt(strings.TrimPrefix(token, latencyToken), 10, 64)
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		c.latency = time.Since(time.Unix(0, sent))
+		c.mu.Unlock()
+	})
+}
@@ -0,0 +1,53 @@
+package varys
+
+type SendMessageParams struct {
+	UID    string
+	Target string
+	Text   string
+}
+
+// formatMessage returns a PRIVMSG or NOTICE of text to target,
+// or an error if target can't be sent to.
+func formatMessage(command string, target string, text string) (string, error) {
+	if err := validTarget(target); err != nil {
+		return "", err
+	}
+	return command + " " + target + " :" + lineQuote.Replace(text), nil
+}
+
+// sendMessage sends text to the target with command, which is PRIVMSG or NOTICE.
+func (v *Varys) sendMessage(command string, params SendMessageParams) error {
+	msg, err := formatMessage(command, params.Target, params.Text)
+	if err != nil {
+		return err
+	}
+
+	if err := v.activate(params.UID); err != nil {
+		return err
+	}
+
+	found := false
+	v.connCall(params.UID, func(c *connection) {
+		found = true
+		c.sendSplit(msg, nil)
+		metricMessagesSent.Inc()
+	})
+	if !found {
+		return errNotConnected
+	}
+	return nil
+}
+
+// SendMessage sends a PRIVMSG to the target.
+//
+// It's split and rate limited just like SendRaw.
+func (v *Varys) SendMessage(params SendMessageParams, _ *struct{}) error {
+	return v.sendMessage("PRIVMSG", params)
+}
+
+// SendNotice sends a NOTICE to the target, as services and bots conventionally reply with.
+//
+// It's split and rate limited just like SendRaw.
+func (v *Varys) SendNotice(params SendMessageParams, _ *struct{}) error {
+	return v.sendMessage("NOTICE", params)
+}
@@ -0,0 +1,22 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatMessage(t *testing.T) {
+	msg, err := formatMessage("NOTICE", "qais", "hello\r\nthere")
+	assert.NoError(t, err)
+	assert.Equal(t, "NOTICE qais :hello there", msg)
+
+	_, err = formatMessage("PRIVMSG", "#a b", "hello")
+	assert.Error(t, err)
+}
+
+func TestSendMessageUnknownUID(t *testing.T) {
+	v := NewVarys(nil)
+	assert.ErrorIs(t, v.SendMessage(SendMessageParams{UID: "123", Target: "#go-nuts", Text: "hello"}, nil), errNotConnected)
+	assert.ErrorIs(t, v.SendNotice(SendMessageParams{UID: "123", Target: "#go-nuts", Text: "hello"}, nil), errNotConnected)
+}
@@ -0,0 +1,30 @@
+package varys
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics are registered with the default Prometheus registry,
+// so they can be served with promhttp.Handler.
+var (
+	// metricConnected is the number of connections currently connected to IRC
+	metricConnected = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "varys",
+		Name:      "connected",
+		Help:      "Number of connections currently connected to IRC.",
+	})
+	// metricMessagesSent is the number of messages sent with SendRaw
+	metricMessagesSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "varys",
+		Name:      "messages_sent_total",
+		Help:      "Number of messages sent to IRC.",
+	})
+	// metricReconnects is the number of reconnection attempts
+	metricReconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "varys",
+		Name:      "reconnects_total",
+		Help:      "Number of attempts to reconnect to IRC.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(metricConnected, metricMessagesSent, metricReconnects)
+}
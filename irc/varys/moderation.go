@@ -0,0 +1,239 @@
+package varys
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// normaliseMask expands a ban mask the way servers do, so that we recognise it when the server confirms the ban.
+// A bare nick becomes "nick!*@*", and a bare host becomes "*!*@host". Extended bans are left alone.
+func normaliseMask(mask string) string {
+	if mask == "" || mask[0] == '$' || mask[0] == '~' {
+		return mask
+	}
+
+	hasNick := strings.ContainsRune(mask, '!')
+	hasHost := strings.ContainsRune(mask, '@')
+	switch {
+	case hasNick && hasHost:
+		return mask
+	case hasNick:
+		return mask + "@*"
+	case hasHost:
+		return "*!" + mask
+	case strings.ContainsAny(mask, ".:"):
+		return "*!*@" + mask
+	default:
+		return mask + "!*@*"
+	}
+}
+
+func kickKey(channel string, target string) string {
+	return queryKey("kick", channel+" "+target)
+}
+
+func banKey(channel string, mask string, adding bool) string {
+	sign := "-"
+	if adding {
+		sign = "+"
+	}
+	return queryKey("ban", channel+" "+sign+mask)
+}
+
+// banToken prefixes the PINGs we send after changing bans. The server replies to
+// commands in order, so once it PONGs, any ban it hasn't echoed was a no-op.
+const banToken = "varys-bans-"
+
+var lastBanPing uint64
+
+func banPingKey(token string) string {
+	return queryKey("banping", token)
+}
+
+// handleModeration completes pending kicks and bans once the server confirms them.
+func (c *connection) handleModeration(conn *irc.Connection) {
+	conn.AddCallback("KICK", func(e *irc.Event) {
+		// KICK <channel> <nick> :<reason>
+		if len(e.Arguments) >= 2 && c.isMe(e.Nick) {
+			c.queries.finish(kickKey(e.Arguments[0], e.Arguments[1]), nil)
+		}
+	})
+
+	conn.AddCallback("MODE", func(e *irc.Event) {
+		// MODE <channel> <modes> [<params>...]
+		if len(e.Arguments) < 2 || !c.isMe(e.Nick) {
+			return
+		}
+		added, removed := c.chanModes().listChanges(e.Arguments[1], e.Arguments[2:], 'b')
+		for _, mask := range added {
+			c.queries.finish(banKey(e.Arguments[0], mask, true), nil)
+		}
+		for _, mask := range removed {
+			c.queries.finish(banKey(e.Arguments[0], mask, false), nil)
+		}
+	})
+
+	conn.AddCallback("PONG", func(e *irc.Event) {
+		if strings.HasPrefix(e.Message(), banToken) {
+			c.finishBanPing(e.Message())
+		}
+	})
+
+	// ERR_NOSUCHCHANNEL, ERR_NOTONCHANNEL and ERR_CHANOPRIVSNEEDED <me> <channel> :<reason>
+	fail := func(e *irc.Event, err error) {
+		if len(e.Arguments) < 2 {
+			return
+		}
+		c.queries.finishPrefix(queryKey("kick", e.Arguments[1])+" ", err)
+		c.queries.finishPrefix(queryKey("ban", e.Arguments[1])+" ", err)
+	}
+	conn.AddCallback("403", func(e *irc.Event) {
+		fail(e, errors.New("no such channel: "+e.Message()))
+	})
+	conn.AddCallback("442", func(e *irc.Event) {
+		fail(e, errors.New("not on channel: "+e.Message()))
+	})
+	conn.AddCallback("482", func(e *irc.Event) {
+		fail(e, errors.New("channel operator privileges needed: "+e.Message()))
+	})
+
+	// ERR_USERNOTINCHANNEL <me> <nick> <channel> :<reason>
+	conn.AddCallback("441", func(e *irc.Event) {
+		if len(e.Arguments) >= 3 {
+			c.queries.finish(kickKey(e.Arguments[2], e.Arguments[1]), errors.New("user not in channel: "+e.Message()))
+		}
+	})
+}
+
+// finishBanPing completes the bans sent before the PING with token.
+// Servers don't echo bans that are already set (or unset), so these succeed quietly.
+func (c *connection) finishBanPing(token string) {
+	var keys []string
+	c.queries.update(banPingKey(token), func(value interface{}) {
+		keys = value.([]string)
+	})
+	for _, key := range keys {
+		c.queries.finish(key, nil)
+	}
+	c.queries.finish(banPingKey(token), nil)
+}
+
+type KickParams struct {
+	UID     string
+	Channel string
+	Target  string
+	Reason  string
+}
+
+// Kick kicks a user from a channel, waiting for the server to accept it.
+// This needs channel operator privileges.
+func (v *Varys) Kick(params KickParams, _ *struct{}) error {
+	if err := validTarget(params.Channel); err != nil {
+		return err
+	}
+	if err := validTarget(params.Target); err != nil {
+		return fmt.Errorf("kick target: %w", err)
+	}
+
+	c, err := v.queryConn(params.UID)
+	if err != nil {
+		return err
+	}
+
+	key := kickKey(params.Channel, params.Target)
+	wait := c.queries.start(key, nil, func() {
+		c.send("KICK " + params.Channel + " " + params.Target + " :" + lineQuote.Replace(params.Reason))
+	})
+
+	_, err = c.queries.wait(key, wait, queryTimeout)
+	return err
+}
+
+type BanParams struct {
+	UID     string
+	Channel string
+	Mask    string
+
+	// Masks are more masks to change at the same time. They are sent in
+	// as few MODE commands as the server's MODES limit allows.
+	Masks []string
+}
+
+func (v *Varys) changeBan(params BanParams, adding bool) error {
+	if err := validTarget(params.Channel); err != nil {
+		return err
+	}
+
+	var masks []string
+	if params.Mask != "" {
+		masks = append(masks, params.Mask)
+	}
+	masks = append(masks, params.Masks...)
+	for i, mask := range masks {
+		if err := validTarget(mask); err != nil {
+			return fmt.Errorf("ban mask %q: %w", mask, err)
+		}
+		masks[i] = normaliseMask(mask)
+	}
+
+	c, err := v.queryConn(params.UID)
+	if err != nil {
+		return err
+	}
+
+	// Only send the masks we aren't already waiting for
+	keys := make([]string, len(masks))
+	waits := make([]<-chan *query, len(masks))
+	var send, sent []string
+	for i, mask := range masks {
+		mask := mask
+		keys[i] = banKey(params.Channel, mask, adding)
+		waits[i] = c.queries.start(keys[i], nil, func() {
+			send = append(send, mask)
+			sent = append(sent, keys[i])
+		})
+	}
+
+	for _, change := range chunkModeChanges(adding, 'b', send, c.modesLimit()) {
+		c.send("MODE " + params.Channel + " " + change)
+	}
+
+	if len(sent) > 0 {
+		token := banToken + strconv.FormatUint(atomic.AddUint64(&lastBanPing, 1), 10)
+		c.queries.start(banPingKey(token), sent, func() {
+			c.send("PING " + token)
+		})
+		defer c.queries.cancel(banPingKey(token))
+	}
+
+	// The server replies to each command at about the same time, so they share a timeout
+	deadline := time.Now().Add(queryTimeout)
+	for i, key := range keys {
+		if _, err := c.queries.wait(key, waits[i], time.Until(deadline)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetBan bans masks from a channel, waiting for the server to accept them.
+// This needs channel operator privileges.
+//
+// Servers don't reply to bans that are already set, so these succeed without changing anything.
+func (v *Varys) SetBan(params BanParams, _ *struct{}) error {
+	return v.changeBan(params, true)
+}
+
+// RemoveBan unbans masks from a channel, waiting for the server to accept them.
+// This needs channel operator privileges.
+//
+// Servers don't reply to removing bans that aren't set, so these succeed without changing anything.
+func (v *Varys) RemoveBan(params BanParams, _ *struct{}) error {
+	return v.changeBan(params, false)
+}
@@ -0,0 +1,58 @@
+package varys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormaliseMask(t *testing.T) {
+	tests := map[string]string{
+		"nick":            "nick!*@*",
+		"nick!user":       "nick!user@*",
+		"user@host":       "*!user@host",
+		"*!*@example.com": "*!*@example.com",
+		"example.com":     "*!*@example.com",
+		"2001:db8::1":     "*!*@2001:db8::1",
+		"$a:account":      "$a:account",
+		"~a:account":      "~a:account",
+	}
+
+	for mask, expected := range tests {
+		assert.Equal(t, expected, normaliseMask(mask), mask)
+	}
+}
+
+func TestModerationValidation(t *testing.T) {
+	v := NewVarys(nil)
+
+	assert.Error(t, v.Kick(KickParams{UID: "123", Channel: "#go-nuts\r\nQUIT", Target: "qais"}, nil))
+	assert.Error(t, v.Kick(KickParams{UID: "123", Channel: "#go-nuts", Target: "qais\r\nQUIT"}, nil))
+	assert.ErrorIs(t, v.Kick(KickParams{UID: "123", Channel: "#go-nuts", Target: "qais"}, nil), errNotConnected)
+
+	assert.Error(t, v.SetBan(BanParams{UID: "123", Channel: "#go nuts", Mask: "qais"}, nil))
+	assert.Error(t, v.SetBan(BanParams{UID: "123", Channel: "#go-nuts", Mask: "qais\r\nQUIT"}, nil))
+	assert.Error(t, v.RemoveBan(BanParams{UID: "123", Channel: "#go-nuts", Masks: []string{"qais", "*!*@a b"}}, nil))
+	assert.ErrorIs(t, v.RemoveBan(BanParams{UID: "123", Channel: "#go-nuts", Masks: []string{"qais"}}, nil), errNotConnected)
+}
+
+func TestFinishBanPing(t *testing.T) {
+	c := &connection{}
+
+	// The server echoed one ban, but not the other, which was already set
+	echoed := banKey("#go-nuts", "qais!*@*", true)
+	noop := banKey("#go-nuts", "*!*@example.com", true)
+	waitEchoed := c.queries.start(echoed, nil, func() {})
+	waitNoop := c.queries.start(noop, nil, func() {})
+	c.queries.start(banPingKey(banToken+"1"), []string{echoed, noop}, func() {})
+
+	c.queries.finish(echoed, nil)
+	c.finishBanPing(banToken + "1")
+
+	_, err := c.queries.wait(echoed, waitEchoed, time.Second)
+	assert.NoError(t, err)
+	_, err = c.queries.wait(noop, waitNoop, time.Second)
+	assert.NoError(t, err)
+	assert.Empty(t, c.queries.pending)
+}
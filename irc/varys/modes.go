@@ -0,0 +1,279 @@
+package varys
+
+import (
+	"strings"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// chanModes describes which channel modes take parameters, as in the CHANMODES and PREFIX ISUPPORT tokens
+type chanModes struct {
+	list   string // Modes for lists of masks, like bans. These always take a parameter, and aren't tracked.
+	always string // Modes that always take a parameter, like the channel key
+	onSet  string // Modes that only take a parameter when set, like the user limit
+	prefix string // Modes giving users a status, like ops. These always take a parameter, and aren't tracked.
+
+	prefixSymbols string // The symbols shown before nicks for each prefix mode, such as "@" for ops
+}
+
+// defaultChanModes are what most servers use, for servers that don't say
+var defaultChanModes = chanModes{
+	list:   "beIq",
+	always: "k",
+	onSet:  "fjl",
+	prefix: "Yqaohv",
+
+	prefixSymbols: "!~&@%+",
+}
+
+// parseChanModes returns the channel modes a server advertised in its CHANMODES and PREFIX
+// ISUPPORT tokens, such as "beI,k,l,imnpst" and "(ov)@+".
+// Tokens that are missing or malformed are taken from defaultChanModes.
+func parseChanModes(isupport map[string]string) chanModes {
+	m := defaultChanModes
+
+	if value, ok := isupport["CHANMODES"]; ok {
+		if types := strings.Split(value, ","); len(types) >= 4 {
+			m.list, m.always, m.onSet = types[0], types[1], types[2]
+		}
+	}
+
+	if value, ok := isupport["PREFIX"]; ok {
+		if modes, symbols, ok := parsePrefix(value); ok {
+			m.prefix, m.prefixSymbols = modes, symbols
+		}
+	}
+	return m
+}
+
+// parsePrefix splits a PREFIX ISUPPORT value, such as "(ov)@+", into its modes and their symbols.
+// A blank value means the server has no prefix modes.
+func parsePrefix(value string) (modes string, symbols string, ok bool) {
+	if value == "" {
+		return "", "", true
+	}
+
+	end := strings.IndexByte(value, ')')
+	if !strings.HasPrefix(value, "(") || end == -1 {
+		return "", "", false
+	}
+	modes, symbols = value[1:end], value[end+1:]
+	return modes, symbols, len(modes) == len(symbols)
+}
+
+// chanModes returns the channel modes the server supports.
+func (c *connection) chanModes() chanModes {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return parseChanModes(c.isupport)
+}
+
+// parse parses a mode change like "+kl-i key 10" into the modes set, with their parameters, and the modes unset.
+// List and prefix modes are skipped.
+func (m chanModes) parse(change string, args []string) (set map[string]string, unset []string) {
+	set = make(map[string]string)
+	next := func() string {
+		if len(args) == 0 {
+			return ""
+		}
+		arg := args[0]
+		args = args[1:]
+		return arg
+	}
+
+	adding := true
+	for _, r := range change {
+		mode := string(r)
+		switch {
+		case r == '+':
+			adding = true
+		case r == '-':
+			adding = false
+		case strings.ContainsRune(m.list+m.prefix, r):
+			next()
+		case adding && strings.ContainsRune(m.always+m.onSet, r):
+			set[mode] = next()
+		case adding:
+			set[mode] = ""
+		default:
+			if strings.ContainsRune(m.always, r) {
+				next()
+			}
+			delete(set, mode)
+			unset = append(unset, mode)
+		}
+	}
+	return set, unset
+}
+
+// listChanges returns the parameters of a list mode, such as bans, added and removed by a mode change.
+func (m chanModes) listChanges(change string, args []string, mode rune) (added []string, removed []string) {
+	adding := true
+	for _, r := range change {
+		switch {
+		case r == '+':
+			adding = true
+		case r == '-':
+			adding = false
+		case strings.ContainsRune(m.list+m.prefix+m.always, r), adding && strings.ContainsRune(m.onSet, r):
+			if len(args) == 0 {
+				return added, removed
+			}
+			arg := args[0]
+			args = args[1:]
+
+			if r != mode {
+				continue
+			}
+			if adding {
+				added = append(added, arg)
+			} else {
+				removed = append(removed, arg)
+			}
+		}
+	}
+	return added, removed
+}
+
+// chunkModeChanges sets or unsets mode with each of params, such as "+bb mask1 mask2",
+// with at most limit parameters in each change.
+func chunkModeChanges(adding bool, mode rune, params []string, limit int) []string {
+	sign := "-"
+	if adding {
+		sign = "+"
+	}
+	if limit < 1 {
+		limit = 1
+	}
+
+	var changes []string
+	for len(params) > 0 {
+		n := limit
+		if n > len(params) {
+			n = len(params)
+		}
+		changes = append(changes, sign+strings.Repeat(string(mode), n)+" "+strings.Join(params[:n], " "))
+		params = params[n:]
+	}
+	return changes
+}
+
+// trackModes keeps the modes of joined channels up to date, and remembers
+// channel keys set by MODE so that we can rejoin after reconnecting.
+func (c *connection) trackModes(conn *irc.Connection) {
+	conn.AddCallback("MODE", func(e *irc.Event) {
+		// MODE <channel> <modes> [<params>...]
+		if len(e.Arguments) < 2 {
+			return
+		}
+		set, unset := c.chanModes().parse(e.Arguments[1], e.Arguments[2:])
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		name := strings.ToLower(e.Arguments[0])
+		channel, ok := c.channels[name]
+		if !ok {
+			return
+		}
+
+		if key, ok := set["k"]; ok {
+			channel.Key = key
+		}
+		for _, mode := range unset {
+			if mode == "k" {
+				channel.Key = ""
+			}
+		}
+
+		if channel.modes != nil {
+			for mode, param := range set {
+				channel.modes[mode] = param
+			}
+			for _, mode := range unset {
+				delete(channel.modes, mode)
+			}
+		}
+		c.channels[name] = channel
+	})
+
+	// RPL_CHANNELMODEIS <me> <channel> <modes> [<params>...]
+	conn.AddCallback("324", func(e *irc.Event) {
+		if len(e.Arguments) < 3 {
+			return
+		}
+		modes, _ := c.chanModes().parse(e.Arguments[2], e.Arguments[3:])
+
+		c.mu.Lock()
+		name := strings.ToLower(e.Arguments[1])
+		if channel, ok := c.channels[name]; ok && channel.Joined {
+			channel.modes = make(map[string]string, len(modes))
+			for mode, param := range modes {
+				channel.modes[mode] = param
+			}
+			c.channels[name] = channel
+		}
+		c.mu.Unlock()
+
+		key := queryKey("modes", e.Arguments[1])
+		c.queries.update(key, func(value interface{}) {
+			*value.(*map[string]string) = modes
+		})
+		c.queries.finish(key, nil)
+	})
+}
+
+// cachedModes returns a copy of the modes of a joined channel, if we know them.
+func (c *connection) cachedModes(channel string) (map[string]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch, ok := c.channels[strings.ToLower(channel)]
+	if !ok || !ch.Joined || ch.modes == nil {
+		return nil, false
+	}
+
+	modes := make(map[string]string, len(ch.modes))
+	for mode, param := range ch.modes {
+		modes[mode] = param
+	}
+	return modes, true
+}
+
+type ChannelModesParams struct {
+	UID     string
+	Channel string
+}
+
+// GetChannelModes returns the modes of a channel, mapping each mode letter to its parameter, if it has one.
+// If the UID is blank, any connection is used.
+//
+// List modes (like bans) and user statuses (like ops) are not included.
+func (v *Varys) GetChannelModes(params ChannelModesParams, result *map[string]string) error {
+	if err := validTarget(params.Channel); err != nil {
+		return err
+	}
+
+	c, err := v.queryConn(params.UID)
+	if err != nil {
+		return err
+	}
+
+	if modes, ok := c.cachedModes(params.Channel); ok {
+		*result = modes
+		return nil
+	}
+
+	key := queryKey("modes", params.Channel)
+	wait := c.queries.start(key, new(map[string]string), func() {
+		c.send("MODE " + params.Channel)
+	})
+
+	value, err := c.queries.wait(key, wait, queryTimeout)
+	if err != nil {
+		return err
+	}
+
+	*result = *value.(*map[string]string)
+	return nil
+}
@@ -0,0 +1,64 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChanModesParse(t *testing.T) {
+	set, unset := defaultChanModes.parse("+ntk", []string{"key"})
+	assert.Equal(t, map[string]string{"n": "", "t": "", "k": "key"}, set)
+	assert.Empty(t, unset)
+
+	// List and prefix modes consume their parameters, but aren't returned
+	set, unset = defaultChanModes.parse("+bol-vk", []string{"*!*@host", "nick", "10", "other", "key"})
+	assert.Equal(t, map[string]string{"l": "10"}, set)
+	assert.Equal(t, []string{"k"}, unset)
+
+	// The limit doesn't take a parameter when unset
+	set, unset = defaultChanModes.parse("-l+i", nil)
+	assert.Equal(t, map[string]string{"i": ""}, set)
+	assert.Equal(t, []string{"l"}, unset)
+}
+
+func TestParseChanModes(t *testing.T) {
+	assert.Equal(t, defaultChanModes, parseChanModes(nil))
+
+	m := parseChanModes(map[string]string{"CHANMODES": "beI,kL,lH,psmntirRcOAQKVCuzNSMTGZ", "PREFIX": "(ov)@+"})
+	assert.Equal(t, chanModes{list: "beI", always: "kL", onSet: "lH", prefix: "ov", prefixSymbols: "@+"}, m)
+
+	// L takes a parameter on this server, and q isn't a prefix
+	set, _ := m.parse("+Lq", []string{"#overflow"})
+	assert.Equal(t, map[string]string{"L": "#overflow", "q": ""}, set)
+
+	// Malformed tokens are ignored
+	assert.Equal(t, defaultChanModes, parseChanModes(map[string]string{"CHANMODES": "b,k", "PREFIX": "(ov)@"}))
+	assert.Equal(t, "", parseChanModes(map[string]string{"PREFIX": ""}).prefix)
+}
+
+func TestChanModesListChanges(t *testing.T) {
+	added, removed := defaultChanModes.listChanges("+bo-b+lk", []string{"a!*@*", "nick", "b!*@*", "10", "key"}, 'b')
+	assert.Equal(t, []string{"a!*@*"}, added)
+	assert.Equal(t, []string{"b!*@*"}, removed)
+
+	// The limit doesn't take a parameter when unset
+	added, removed = defaultChanModes.listChanges("-l+b", []string{"a!*@*"}, 'b')
+	assert.Equal(t, []string{"a!*@*"}, added)
+	assert.Empty(t, removed)
+}
+
+func TestChunkModeChanges(t *testing.T) {
+	masks := []string{"a!*@*", "b!*@*", "c!*@*", "d!*@*"}
+	assert.Equal(t, []string{"+bbb a!*@* b!*@* c!*@*", "+b d!*@*"}, chunkModeChanges(true, 'b', masks, 3))
+	assert.Equal(t, []string{"-b a!*@*", "-b b!*@*"}, chunkModeChanges(false, 'b', masks[:2], 1))
+	assert.Empty(t, chunkModeChanges(true, 'b', nil, 3))
+}
+
+func TestGetChannelModesValidation(t *testing.T) {
+	v := NewVarys(nil)
+	var modes map[string]string
+
+	assert.Equal(t, validTarget("#go-nuts\r\nQUIT"), v.GetChannelModes(ChannelModesParams{UID: "123", Channel: "#go-nuts\r\nQUIT"}, &modes))
+	assert.ErrorIs(t, v.GetChannelModes(ChannelModesParams{UID: "123", Channel: "#go-nuts"}, &modes), errNotConnected)
+}
@@ -0,0 +1,276 @@
+package varys
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// isonInterval is how often we poll with ISON, on servers without MONITOR
+const isonInterval = time.Minute
+
+// monitoredNick is a nick we are watching, and whether it was last seen online
+type monitoredNick struct {
+	Nick   string
+	Online bool
+
+	// known is false until the server has told us whether the nick is online
+	known bool
+}
+
+// groupItems splits items into groups that fit on one line after prefix, when joined by sep.
+func groupItems(prefix string, sep string, items []string) [][]string {
+	// Leave room for the trailing CRLF
+	max := maxLineLength - 2 - len(prefix)
+
+	var groups [][]string
+	var group []string
+	length := 0
+	for _, item := range items {
+		if len(group) > 0 && length+len(sep)+len(item) > max {
+			groups = append(groups, group)
+			group, length = nil, 0
+		}
+		if len(group) > 0 {
+			length += len(sep)
+		}
+		group = append(group, item)
+		length += len(item)
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// sendMonitor sends MONITOR commands changing the monitor list, such as "MONITOR + a,b".
+func sendMonitor(send func(string), change string, nicks []string) {
+	prefix := "MONITOR " + change + " "
+	for _, group := range groupItems(prefix, ",", nicks) {
+		send(prefix + strings.Join(group, ","))
+	}
+}
+
+// setOnline records whether a monitored nick is online, sending an event if that changed.
+func (c *connection) setOnline(nick string, online bool) {
+	name := strings.ToLower(nick)
+
+	c.mu.Lock()
+	m, ok := c.monitored[name]
+	changed := ok && (!m.known || m.Online != online)
+	if ok {
+		m.Online = online
+		m.known = true
+		c.monitored[name] = m
+	}
+	c.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if online {
+		c.pushVarysEvent(EventMonitorOnline, m.Nick)
+	} else {
+		c.pushVarysEvent(EventMonitorOffline, m.Nick)
+	}
+}
+
+// monitoredNicks returns the nicks we are watching.
+func (c *connection) monitoredNicks() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	nicks := make([]string, 0, len(c.monitored))
+	for _, m := range c.monitored {
+		nicks = append(nicks, m.Nick)
+	}
+	return nicks
+}
+
+// trackMonitor watches the monitored nicks on conn, using MONITOR if the server supports it,
+// and otherwise polling with ISON.
+func (c *connection) trackMonitor(conn *irc.Connection) {
+	// Once registration has finished, we know whether the server supports MONITOR.
+	// The MOTD can be asked for again later, so this only happens once per connection.
+	var once sync.Once
+	ready := func(e *irc.Event) {
+		once.Do(func() { c.startMonitor(conn) })
+	}
+	conn.AddCallback("376", ready) // RPL_ENDOFMOTD
+	conn.AddCallback("422", ready) // ERR_NOMOTD
+
+	// RPL_MONONLINE <me> :<nick!user@host>[,...] and RPL_MONOFFLINE <me> :<nick>[,...]
+	monitorReply := func(online bool) func(e *irc.Event) {
+		return func(e *irc.Event) {
+			for _, target := range strings.Split(e.Message(), ",") {
+				nick := strings.SplitN(target, "!", 2)[0]
+				c.setOnline(nick, online)
+			}
+		}
+	}
+	conn.AddCallback("730", monitorReply(true))
+	conn.AddCallback("731", monitorReply(false))
+
+	// RPL_ISON <me> :<nick>...
+	conn.AddCallback("303", func(e *irc.Event) {
+		c.mu.Lock()
+		if len(c.isonPending) == 0 {
+			c.mu.Unlock()
+			return
+		}
+		asked := c.isonPending[0]
+		c.isonPending = c.isonPending[1:]
+		c.mu.Unlock()
+
+		online := make(map[string]bool)
+		for _, nick := range strings.Fields(e.Message()) {
+			online[strings.ToLower(nick)] = true
+		}
+		for _, nick := range asked {
+			c.setOnline(nick, online[strings.ToLower(nick)])
+		}
+	})
+}
+
+// startMonitor starts watching the monitored nicks on a freshly registered conn.
+func (c *connection) startMonitor(conn *irc.Connection) {
+	c.mu.Lock()
+	for name, m := range c.monitored {
+		m.known = false
+		c.monitored[name] = m
+	}
+	monitor := c.supports("MONITOR")
+	c.mu.Unlock()
+
+	if monitor {
+		sendMonitor(conn.SendRaw, "+", c.monitoredNicks())
+	} else {
+		go c.pollISON(conn)
+	}
+}
+
+// pollISON asks whether the monitored nicks are online every isonInterval,
+// until conn is replaced or we quit.
+func (c *connection) pollISON(conn *irc.Connection) {
+	c.mu.Lock()
+	c.isonPending = nil
+	c.mu.Unlock()
+
+	ticker := time.NewTicker(isonInterval)
+	defer ticker.Stop()
+
+	for {
+		if c.live() != conn {
+			return
+		}
+		c.sendISON(conn)
+
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendISON asks whether the monitored nicks are online.
+func (c *connection) sendISON(conn *irc.Connection) {
+	for _, group := range groupItems("ISON ", " ", c.monitoredNicks()) {
+		c.mu.Lock()
+		c.isonPending = append(c.isonPending, group)
+		c.mu.Unlock()
+
+		conn.SendRaw("ISON " + strings.Join(group, " "))
+	}
+}
+
+type MonitorParams struct {
+	UID   string
+	Nicks []string
+}
+
+// validateNicks returns an error if any of nicks can't be sent in a MONITOR or ISON line.
+func (p MonitorParams) validateNicks() error {
+	for _, nick := range p.Nicks {
+		if err := validTarget(nick); err != nil {
+			return fmt.Errorf("nick %q: %w", nick, err)
+		}
+	}
+	return nil
+}
+
+// Monitor watches nicks, sending VARYS_MONITOR_ONLINE and VARYS_MONITOR_OFFLINE events
+// when they come online or go offline.
+//
+// Nicks stay monitored after reconnecting.
+func (v *Varys) Monitor(params MonitorParams, _ *struct{}) error {
+	if err := params.validateNicks(); err != nil {
+		return err
+	}
+
+	found := false
+	v.connCall(params.UID, func(c *connection) {
+		found = true
+
+		var added []string
+		c.mu.Lock()
+		for _, nick := range params.Nicks {
+			name := strings.ToLower(nick)
+			if _, ok := c.monitored[name]; !ok {
+				c.monitored[name] = monitoredNick{Nick: nick}
+				added = append(added, nick)
+			}
+		}
+		monitor := c.supports("MONITOR")
+		c.mu.Unlock()
+
+		conn := c.live()
+		if conn == nil || len(added) == 0 {
+			return
+		}
+		if monitor {
+			sendMonitor(c.send, "+", added)
+		} else {
+			c.sendISON(conn)
+		}
+	})
+	if !found {
+		return errNotConnected
+	}
+	return nil
+}
+
+// Unmonitor stops watching nicks.
+func (v *Varys) Unmonitor(params MonitorParams, _ *struct{}) error {
+	if err := params.validateNicks(); err != nil {
+		return err
+	}
+
+	found := false
+	v.connCall(params.UID, func(c *connection) {
+		found = true
+
+		var removed []string
+		c.mu.Lock()
+		for _, nick := range params.Nicks {
+			name := strings.ToLower(nick)
+			if _, ok := c.monitored[name]; ok {
+				delete(c.monitored, name)
+				removed = append(removed, nick)
+			}
+		}
+		monitor := c.supports("MONITOR")
+		c.mu.Unlock()
+
+		if monitor && len(removed) > 0 {
+			sendMonitor(c.send, "-", removed)
+		}
+	})
+	if !found {
+		return errNotConnected
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package varys
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupItems(t *testing.T) {
+	assert.Empty(t, groupItems("ISON ", " ", nil))
+	assert.Equal(t, [][]string{{"a", "b", "c"}}, groupItems("ISON ", " ", []string{"a", "b", "c"}))
+
+	// Each group fits on one line
+	nick := strings.Repeat("n", 30)
+	nicks := make([]string, 40)
+	for i := range nicks {
+		nicks[i] = nick
+	}
+
+	groups := groupItems("MONITOR + ", ",", nicks)
+	assert.Len(t, groups, 3)
+	total := 0
+	for _, group := range groups {
+		line := "MONITOR + " + strings.Join(group, ",") + "\r\n"
+		assert.LessOrEqual(t, len(line), maxLineLength)
+		total += len(group)
+	}
+	assert.Equal(t, len(nicks), total)
+}
+
+func TestMonitorValidation(t *testing.T) {
+	v := NewVarys(nil)
+	assert.ErrorIs(t, v.Monitor(MonitorParams{UID: "123", Nicks: []string{"qais"}}, nil), errNotConnected)
+	assert.ErrorIs(t, v.Unmonitor(MonitorParams{UID: "123", Nicks: []string{"qais"}}, nil), errNotConnected)
+
+	c := newConnection(ConnectParams{UID: "123"}, RateLimit{})
+	v.add(c)
+	for _, nick := range []string{"qais rest", "a,b", "qais\r\nQUIT", "qais\x00", ""} {
+		assert.Error(t, v.Monitor(MonitorParams{UID: "123", Nicks: []string{"ok", nick}}, nil), nick)
+		assert.Error(t, v.Unmonitor(MonitorParams{UID: "123", Nicks: []string{nick}}, nil), nick)
+	}
+	assert.Empty(t, c.monitored, "nothing is monitored if any nick is invalid")
+
+	assert.NoError(t, v.Monitor(MonitorParams{UID: "123", Nicks: []string{"qais"}}, nil))
+	assert.Len(t, c.monitored, 1)
+}
@@ -0,0 +1,70 @@
+package varys
+
+import (
+	"errors"
+	"strings"
+)
+
+// lineQuote strips characters that would break the IRC line
+var lineQuote = strings.NewReplacer("\r", "", "\n", " ")
+
+type SendMultiParams struct {
+	UID     string
+	Targets []string
+	Text    string
+}
+
+// SendResult is the outcome of sending to one target
+type SendResult struct {
+	Target string
+
+	// Error is blank if the message was sent
+	Error string
+}
+
+// validTarget returns an error if target can't be sent to.
+func validTarget(target string) error {
+	switch {
+	case target == "":
+		return errors.New("target is blank")
+	case strings.HasPrefix(target, ":"), strings.ContainsAny(target, " ,\r\n\x00"):
+		return errors.New("target contains invalid characters")
+	}
+	return nil
+}
+
+// SendMulti sends a PRIVMSG with the same text to each target.
+//
+// It's split and rate limited just like SendRaw. Results are in the same order as the targets.
+func (v *Varys) SendMulti(params SendMultiParams, result *[]SendResult) error {
+	if err := v.activate(params.UID); err != nil {
+		return err
+	}
+
+	results := make([]SendResult, len(params.Targets))
+	var msgs []string
+	for i, target := range params.Targets {
+		msg, err := formatMessage("PRIVMSG", target, params.Text)
+		if err != nil {
+			results[i] = SendResult{Target: target, Error: err.Error()}
+			continue
+		}
+		results[i] = SendResult{Target: target}
+		msgs = append(msgs, msg)
+	}
+
+	found := false
+	v.connCall(params.UID, func(c *connection) {
+		found = true
+		for _, msg := range msgs {
+			c.sendSplit(msg, nil)
+			metricMessagesSent.Inc()
+		}
+	})
+	if !found {
+		return errNotConnected
+	}
+
+	*result = results
+	return nil
+}
@@ -0,0 +1,16 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidTarget(t *testing.T) {
+	assert.NoError(t, validTarget("#channel"))
+	assert.NoError(t, validTarget("nick"))
+	assert.Error(t, validTarget(""))
+	assert.Error(t, validTarget("#a,#b"))
+	assert.Error(t, validTarget("#a b"))
+	assert.Error(t, validTarget(":nick"))
+}
@@ -0,0 +1,154 @@
+package varys
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// multilineCaps are the IRCv3 capabilities for sending multi-line messages as one, requested when available
+var multilineCaps = []string{"batch", "draft/multiline"}
+
+// batchRefs numbers the batches we open, so that each has a unique reference
+var batchRefs uint64
+
+// multilineLimits are the limits the server advertises for draft/multiline, such as "max-bytes=4096,max-lines=24".
+// A zero limit means there isn't one.
+type multilineLimits struct {
+	maxBytes int
+	maxLines int
+}
+
+func parseMultilineLimits(value string) multilineLimits {
+	var limits multilineLimits
+	for _, field := range strings.Split(value, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		n, err := strconv.Atoi(kv[1])
+		if err != nil || n <= 0 {
+			continue
+		}
+		switch kv[0] {
+		case "max-bytes":
+			limits.maxBytes = n
+		case "max-lines":
+			limits.maxLines = n
+		}
+	}
+	return limits
+}
+
+// multilineMessage is one PRIVMSG in a multiline batch.
+// If concat is true, it continues the previous line, rather than starting a new one.
+type multilineMessage struct {
+	text   string
+	concat bool
+}
+
+// multilineBatches arranges lines into as few batches as the limits allow.
+//
+// Lines too long for one PRIVMSG are split, with the continuations concatenated.
+// A batch can't start with a continuation, so a line that spans two batches is shown as two lines.
+func multilineBatches(target string, lines []string, nick string, user string, limits multilineLimits) [][]multilineMessage {
+	header := "PRIVMSG " + target + " :"
+
+	var batches [][]multilineMessage
+	var batch []multilineMessage
+	size := 0
+	for _, line := range lines {
+		line = lineQuote.Replace(line)
+
+		for i, chunk := range splitMessage(header+line, nick, user) {
+			m := multilineMessage{text: strings.TrimPrefix(chunk, header), concat: i > 0}
+
+			// Lines are joined with a newline, which counts towards max-bytes
+			length := len(m.text)
+			if len(batch) > 0 && !m.concat {
+				length++
+			}
+
+			full := limits.maxLines > 0 && len(batch) >= limits.maxLines
+			if limits.maxBytes > 0 && size+length > limits.maxBytes {
+				full = true
+			}
+			if full && len(batch) > 0 {
+				batches = append(batches, batch)
+				batch, size = nil, 0
+				m.concat = false
+				length = len(m.text)
+			}
+
+			batch = append(batch, m)
+			size += length
+		}
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// sendLines sends lines to target as one multi-line message if the server supports it,
+// and otherwise as a PRIVMSG for each line.
+func (c *connection) sendLines(target string, lines []string) {
+	conn := c.irc()
+	if !hasCap(conn, "draft/multiline") || !hasCap(conn, "batch") {
+		for _, line := range lines {
+			if line != "" {
+				c.sendSplit("PRIVMSG "+target+" :"+lineQuote.Replace(line), nil)
+			}
+		}
+		return
+	}
+
+	c.mu.Lock()
+	limits := parseMultilineLimits(c.advertised["draft/multiline"])
+	c.mu.Unlock()
+
+	cs := c.encoding()
+	for _, batch := range multilineBatches(target, lines, c.currentNick(), c.params.Username, limits) {
+		ref := "varys" + strconv.FormatUint(atomic.AddUint64(&batchRefs, 1), 36)
+
+		c.send("BATCH +" + ref + " draft/multiline " + target)
+		for _, m := range batch {
+			tags := map[string]string{"batch": ref}
+			if m.concat {
+				tags["draft/multiline-concat"] = ""
+			}
+			c.send(formatTags(tags) + cs.encode("PRIVMSG "+target+" :"+m.text))
+		}
+		c.send("BATCH -" + ref)
+	}
+}
+
+type SendLinesParams struct {
+	UID    string
+	Target string
+	Lines  []string
+}
+
+// SendLines sends lines to a target as a single multi-line message, on servers supporting draft/multiline.
+// Other servers are sent a PRIVMSG for each line, skipping blank lines.
+//
+// Like SendRaw, long lines are split and messages are rate limited.
+func (v *Varys) SendLines(params SendLinesParams, _ *struct{}) error {
+	if err := validTarget(params.Target); err != nil {
+		return err
+	}
+	if err := v.activate(params.UID); err != nil {
+		return err
+	}
+
+	found := false
+	v.connCall(params.UID, func(c *connection) {
+		found = true
+		c.sendLines(params.Target, params.Lines)
+		metricMessagesSent.Inc()
+	})
+	if !found {
+		return errNotConnected
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package varys
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMultilineLimits(t *testing.T) {
+	assert.Equal(t, multilineLimits{4096, 24}, parseMultilineLimits("max-bytes=4096,max-lines=24"))
+	assert.Equal(t, multilineLimits{maxBytes: 4096}, parseMultilineLimits("max-bytes=4096"))
+	assert.Equal(t, multilineLimits{}, parseMultilineLimits(""))
+}
+
+func TestMultilineBatches(t *testing.T) {
+	batches := multilineBatches("#channel", []string{"hello", "", "world"}, "qais", "qais", multilineLimits{})
+	assert.Equal(t, [][]multilineMessage{{{text: "hello"}, {text: ""}, {text: "world"}}}, batches)
+
+	// Limit the number of lines
+	batches = multilineBatches("#channel", []string{"a", "b", "c"}, "qais", "qais", multilineLimits{maxLines: 2})
+	assert.Equal(t, [][]multilineMessage{{{text: "a"}, {text: "b"}}, {{text: "c"}}}, batches)
+
+	// "ab\ncd" is five bytes, so "ef" doesn't fit
+	batches = multilineBatches("#channel", []string{"ab", "cd", "ef"}, "qais", "qais", multilineLimits{maxBytes: 6})
+	assert.Equal(t, [][]multilineMessage{{{text: "ab"}, {text: "cd"}}, {{text: "ef"}}}, batches)
+
+	// Long lines are concatenated
+	long := strings.Repeat("a", 600)
+	batches = multilineBatches("#channel", []string{long}, "qais", "qais", multilineLimits{})
+	if assert.Len(t, batches, 1) && assert.Len(t, batches[0], 2) {
+		assert.False(t, batches[0][0].concat)
+		assert.True(t, batches[0][1].concat)
+		assert.Equal(t, long, batches[0][0].text+batches[0][1].text)
+	}
+}
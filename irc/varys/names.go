@@ -0,0 +1,100 @@
+package varys
+
+import (
+	"strings"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// ChannelMember is a user in a channel, as listed by NAMES
+type ChannelMember struct {
+	Nick string
+
+	// Prefixes are the status symbols shown before the nick, highest first, such as "@" for ops or "+" for voice.
+	// Servers only show the highest unless they support multi-prefix.
+	Prefixes string
+	// Modes are the channel modes matching Prefixes, such as "o" or "v"
+	Modes string
+}
+
+// parseMember parses a name from RPL_NAMREPLY, such as "@+qaisjp" or "@qaisjp!user@host".
+func (m chanModes) parseMember(name string) ChannelMember {
+	var member ChannelMember
+	for name != "" {
+		i := strings.IndexByte(m.prefixSymbols, name[0])
+		if i == -1 {
+			break
+		}
+		member.Prefixes += name[:1]
+		member.Modes += m.prefix[i : i+1]
+		name = name[1:]
+	}
+
+	// Servers supporting userhost-in-names send the whole hostmask
+	if i := strings.IndexByte(name, '!'); i != -1 {
+		name = name[:i]
+	}
+	member.Nick = name
+	return member
+}
+
+type names struct {
+	members []ChannelMember
+}
+
+// handleNames collects NAMES replies into the pending names queries.
+func (c *connection) handleNames(conn *irc.Connection) {
+	// RPL_NAMREPLY <me> <symbol> <channel> :{[prefix]<nick>}
+	conn.AddCallback("353", func(e *irc.Event) {
+		if len(e.Arguments) < 4 {
+			return
+		}
+		modes := c.chanModes()
+		c.queries.update(queryKey("names", e.Arguments[2]), func(value interface{}) {
+			n := value.(*names)
+			for _, name := range strings.Fields(e.Arguments[3]) {
+				n.members = append(n.members, modes.parseMember(name))
+			}
+		})
+	})
+
+	// RPL_ENDOFNAMES <me> <channel> :End of /NAMES list
+	conn.AddCallback("366", func(e *irc.Event) {
+		if len(e.Arguments) < 2 {
+			return
+		}
+		c.queries.finish(queryKey("names", e.Arguments[1]), nil)
+	})
+}
+
+type NamesParams struct {
+	UID     string
+	Channel string
+}
+
+// Names lists the members of a channel. If the UID is blank, any connection is used.
+//
+// Channels we aren't in may be listed as empty, or hide their invisible members.
+func (v *Varys) Names(params NamesParams, result *[]ChannelMember) error {
+	if err := validTarget(params.Channel); err != nil {
+		return err
+	}
+
+	c, err := v.queryConn(params.UID)
+	if err != nil {
+		return err
+	}
+
+	key := queryKey("names", params.Channel)
+	wait := c.queries.start(key, &names{}, func() {
+		c.send("NAMES " + params.Channel)
+	})
+
+	value, err := c.queries.wait(key, wait, queryTimeout)
+	if err != nil {
+		return err
+	}
+
+	*result = value.(*names).members
+	return nil
+}
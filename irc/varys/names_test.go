@@ -0,0 +1,37 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMember(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected ChannelMember
+	}{
+		{"qaisjp", ChannelMember{Nick: "qaisjp"}},
+		{"@qaisjp", ChannelMember{Nick: "qaisjp", Prefixes: "@", Modes: "o"}},
+		{"+qaisjp", ChannelMember{Nick: "qaisjp", Prefixes: "+", Modes: "v"}},
+		{"~@+qaisjp", ChannelMember{Nick: "qaisjp", Prefixes: "~@+", Modes: "qov"}},
+		{"%qaisjp!qais@example.com", ChannelMember{Nick: "qaisjp", Prefixes: "%", Modes: "h"}},
+		{"[qais]", ChannelMember{Nick: "[qais]"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, defaultChanModes.parseMember(test.name), test.name)
+	}
+
+	// Symbols the server doesn't use as prefixes are part of the nick
+	m := parseChanModes(map[string]string{"PREFIX": "(ov)@+"})
+	assert.Equal(t, ChannelMember{Nick: "~qaisjp", Prefixes: "@", Modes: "o"}, m.parseMember("@~qaisjp"))
+}
+
+func TestNamesValidation(t *testing.T) {
+	v := NewVarys(nil)
+	var members []ChannelMember
+
+	assert.Equal(t, validTarget("#go-nuts\r\nQUIT"), v.Names(NamesParams{UID: "123", Channel: "#go-nuts\r\nQUIT"}, &members))
+	assert.ErrorIs(t, v.Names(NamesParams{UID: "123", Channel: "#go-nuts"}, &members), errNotConnected)
+}
@@ -0,0 +1,180 @@
+package varys
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	ircnick "github.com/qaisjp/go-discord-irc/irc/nick"
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// Defaults for SetupParams' nick collision fields
+const (
+	defaultNickCollisionSuffix   = "_"
+	defaultNickCollisionAttempts = 3
+)
+
+func (p SetupParams) maxNickLength() int {
+	if p.MaxNickLength > 0 {
+		return p.MaxNickLength
+	}
+	return ircnick.MAXLENGTH
+}
+
+func (p SetupParams) nickCollisionSuffix() string {
+	if p.NickCollisionSuffix != "" {
+		return p.NickCollisionSuffix
+	}
+	return defaultNickCollisionSuffix
+}
+
+func (p SetupParams) nickCollisionAttempts() int {
+	if p.NickCollisionAttempts > 0 {
+		return p.NickCollisionAttempts
+	}
+	return defaultNickCollisionAttempts
+}
+
+// collisionNick returns the nick to try once nick has been rejected attempt times.
+//
+// The collision suffix is inserted before bridgeSuffix, and nick is
+// truncated so that the result is never longer than maxLength.
+// ok is false if that would leave nothing of nick.
+func collisionNick(nick string, bridgeSuffix string, suffix string, attempt int, maxLength int) (_ string, ok bool) {
+	return insertSuffix(nick, bridgeSuffix, strings.Repeat(suffix, attempt), maxLength)
+}
+
+// nickHashLength is how many hex digits of the hash HashedNick uses
+const nickHashLength = 4
+
+// HashedNick returns the nick to try once nick has been rejected attempt times, like collisionNick,
+// but with a short hash of uid as the suffix instead of underscores, such as "qaisjp_1a2b~d".
+//
+// The hash only depends on uid and attempt, so the same user gets the same nick every time,
+// including after reconnecting. Clients can use this to pick nicks the same way Varys does.
+//
+// ok is false if the hash and bridgeSuffix leave no room for any of nick in maxLength.
+func HashedNick(nick string, uid string, bridgeSuffix string, attempt int, maxLength int) (_ string, ok bool) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(uid))
+	if attempt > 1 {
+		_, _ = h.Write([]byte("/" + strconv.Itoa(attempt)))
+	}
+	hash := fmt.Sprintf("%08x", h.Sum32())[:nickHashLength]
+	return insertSuffix(nick, bridgeSuffix, "_"+hash, maxLength)
+}
+
+// insertSuffix inserts suffix before bridgeSuffix, truncating nick on a rune boundary
+// so that the result is never longer than maxLength.
+//
+// ok is false if there isn't room to keep at least the first rune of nick.
+func insertSuffix(nick string, bridgeSuffix string, suffix string, maxLength int) (_ string, ok bool) {
+	var tail string
+	if bridgeSuffix != "" && strings.HasSuffix(nick, bridgeSuffix) {
+		nick = strings.TrimSuffix(nick, bridgeSuffix)
+		tail = bridgeSuffix
+	}
+
+	tail = suffix + tail
+	if length := maxLength - len(tail); len(nick) > length {
+		if length <= 0 {
+			return "", false
+		}
+		for length > 0 && !utf8.RuneStart(nick[length]) {
+			length--
+		}
+		if length == 0 {
+			return "", false
+		}
+		nick = nick[:length]
+	}
+	return nick + tail, true
+}
+
+// handleNickCollisions replaces go-ircevent's default 433 handling:
+// we try a bounded number of alternative nicks, and then give up.
+//
+// If we are still registering, giving up fails the registration.
+func (v *Varys) handleNickCollisions(c *connection, conn *irc.Connection, reg *registration) {
+	conn.ClearCallback("433")
+	conn.AddCallback("433", func(e *irc.Event) {
+		c.mu.Lock()
+		c.nickAttempts++
+		attempt := c.nickAttempts
+		nick := c.nick
+		c.mu.Unlock()
+
+		if attempt > v.config().nickCollisionAttempts() {
+			reg.report(fmt.Errorf("%w: %q, and so were %d alternatives", ErrNickInUse, nick, attempt-1))
+			return
+		}
+
+		config := v.config()
+		maxLength := c.nickLength(config.maxNickLength())
+		var next string
+		var ok bool
+		if config.NickCollisionHash {
+			next, ok = HashedNick(nick, c.params.UID, config.NickSuffix, attempt, maxLength)
+		} else {
+			next, ok = collisionNick(nick, config.NickSuffix, config.nickCollisionSuffix(), attempt, maxLength)
+		}
+		if !ok {
+			reg.report(fmt.Errorf("%w: %q, and no alternative fits in %d characters", ErrNickInUse, nick, maxLength))
+			return
+		}
+		conn.Nick(next)
+	})
+
+	conn.AddCallback("001", func(e *irc.Event) {
+		c.mu.Lock()
+		c.nickAttempts = 0
+		c.mu.Unlock()
+	})
+}
+
+// trackNick keeps track of the nick the server knows us by, which can change
+// without us asking for it, such as when services force us to change nick.
+//
+// go-ircevent only notices our own nick changes, so we track it ourselves.
+func (c *connection) trackNick(conn *irc.Connection) {
+	conn.AddCallback("001", func(e *irc.Event) {
+		if len(e.Arguments) == 0 {
+			return
+		}
+		c.mu.Lock()
+		c.current = e.Arguments[0]
+		c.mu.Unlock()
+	})
+
+	conn.AddCallback("NICK", func(e *irc.Event) {
+		nick := e.Message()
+
+		c.mu.Lock()
+		old := c.current
+		ours := strings.EqualFold(e.Nick, old)
+		if ours {
+			c.current = nick
+			c.nickAttempts = 0
+		}
+		c.mu.Unlock()
+
+		if ours {
+			c.pushVarysEvent(EventNickChanged, old, nick)
+		}
+	})
+}
+
+// currentNick returns the nick the server knows us by.
+func (c *connection) currentNick() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+// isMe returns true if nick is the nick the server knows us by.
+func (c *connection) isMe(nick string) bool {
+	return strings.EqualFold(nick, c.currentNick())
+}
@@ -0,0 +1,62 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollisionNick(t *testing.T) {
+	nick := func(nick string, bridgeSuffix string, attempt int, maxLength int) string {
+		next, ok := collisionNick(nick, bridgeSuffix, "_", attempt, maxLength)
+		assert.True(t, ok, nick)
+		assert.LessOrEqual(t, len(next), maxLength, nick)
+		return next
+	}
+
+	assert.Equal(t, "qaisjp_", nick("qaisjp", "", 1, 30))
+	assert.Equal(t, "qaisjp__", nick("qaisjp", "", 2, 30))
+
+	// The collision suffix goes before the bridge suffix
+	assert.Equal(t, "qaisjp_~d", nick("qaisjp~d", "~d", 1, 30))
+
+	// Nicks are truncated, keeping both suffixes
+	assert.Equal(t, "qais__~d", nick("qaisjp~d", "~d", 2, 8))
+	assert.Equal(t, "q__~d", nick("qaisjp~d", "~d", 2, 5))
+
+	// Truncating never splits a rune
+	assert.Equal(t, "qé_", nick("qéé", "", 1, 5))
+	assert.Equal(t, "qé_", nick("qéé", "", 1, 4))
+
+	// We give up when there is no room for any of the nick
+	for _, maxLength := range []int{2, 4} {
+		_, ok := collisionNick("qaisjp~d", "~d", "_", 2, maxLength)
+		assert.False(t, ok, maxLength)
+	}
+	_, ok := collisionNick("ééé", "", "_", 1, 2)
+	assert.False(t, ok)
+}
+
+func TestHashedNick(t *testing.T) {
+	hashed := func(nick string, uid string, attempt int, maxLength int) string {
+		next, ok := HashedNick(nick, uid, "~d", attempt, maxLength)
+		assert.True(t, ok, nick)
+		return next
+	}
+
+	nick := hashed("qaisjp~d", "123456789", 1, 30)
+	assert.Equal(t, nick, hashed("qaisjp~d", "123456789", 1, 30), "the same UID should get the same nick")
+	assert.Len(t, nick, len("qaisjp_1234~d"))
+	assert.Equal(t, "qaisjp_", nick[:7])
+	assert.Equal(t, "~d", nick[len(nick)-2:])
+
+	assert.NotEqual(t, nick, hashed("qaisjp~d", "987654321", 1, 30))
+	assert.NotEqual(t, nick, hashed("qaisjp~d", "123456789", 2, 30))
+
+	// Nicks are truncated, keeping the hash and the bridge suffix
+	short := hashed("qaisjp~d", "123456789", 1, 10)
+	assert.Equal(t, "qai"+nick[6:], short)
+
+	_, ok := HashedNick("qaisjp~d", "123456789", "~d", 1, 7)
+	assert.False(t, ok)
+}
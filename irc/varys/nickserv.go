@@ -0,0 +1,60 @@
+package varys
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// nickServTimeout is how long we wait for NickServ to identify us
+// before considering the connection ready anyway.
+const nickServTimeout = 10 * time.Second
+
+// defaultNickServFormat is the command used to identify with NickServ.
+// The account is named, as we may be using another nick if ours was taken.
+const defaultNickServFormat = "PRIVMSG NickServ :IDENTIFY ${NICK} ${PASSWORD}"
+
+// nickServCommand returns the command used to identify as nick, the nick we asked for,
+// or a blank string if we should not identify with NickServ.
+func (p SetupParams) nickServCommand(nick string) string {
+	if p.NickServPassword == "" {
+		return ""
+	}
+
+	format := p.NickServFormat
+	if format == "" {
+		format = defaultNickServFormat
+	}
+
+	format = strings.ReplaceAll(format, "${NICK}", nick)
+	return strings.ReplaceAll(format, "${PASSWORD}", p.NickServPassword)
+}
+
+// watchIdentified returns a channel that is closed once services tell us we have identified.
+func watchIdentified(conn *irc.Connection) <-chan struct{} {
+	identified := make(chan struct{})
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			close(identified)
+		})
+	}
+
+	// RPL_LOGGEDIN
+	conn.AddCallback("900", func(e *irc.Event) {
+		done()
+	})
+
+	conn.AddCallback("NOTICE", func(e *irc.Event) {
+		if !strings.EqualFold(e.Nick, "NickServ") {
+			return
+		}
+		if msg := strings.ToLower(e.Message()); strings.Contains(msg, "you are now identified") || strings.Contains(msg, "you are now logged in") {
+			done()
+		}
+	})
+
+	return identified
+}
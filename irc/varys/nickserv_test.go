@@ -0,0 +1,15 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNickServCommand(t *testing.T) {
+	assert.Equal(t, "", SetupParams{}.nickServCommand("qais"))
+
+	// The account is named, in case qais was taken and we are qais_
+	assert.Equal(t, "PRIVMSG NickServ :IDENTIFY qais hunter2", SetupParams{NickServPassword: "hunter2"}.nickServCommand("qais"))
+	assert.Equal(t, "NS ID hunter2", SetupParams{NickServPassword: "hunter2", NickServFormat: "NS ID ${PASSWORD}"}.nickServCommand("qais"))
+}
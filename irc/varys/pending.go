@@ -0,0 +1,118 @@
+package varys
+
+import (
+	"strconv"
+	"strings"
+)
+
+// maxPendingMessages is how many messages are held for a connection while it connects or reconnects
+const maxPendingMessages = 100
+
+// hold keeps msg to send once the server has welcomed us, after connecting or reconnecting.
+// Messages are dropped if we aren't waiting to be welcomed, such as after quitting, or if too many are held.
+func (c *connection) hold(msg string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	if !c.reconnecting {
+		return
+	}
+	if len(c.pending) >= maxPendingMessages {
+		c.pendingDropped++
+		return
+	}
+	c.pending = append(c.pending, msg)
+}
+
+// rejoiningChannel returns the channel msg is sent to, lowercased, if it's one we have yet to rejoin.
+// c.mu must be held.
+func (c *connection) rejoiningChannel(msg string) (string, bool) {
+	fields := strings.Fields(msg)
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return "", false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "PRIVMSG", "NOTICE", "TAGMSG":
+	default:
+		return "", false
+	}
+
+	name := strings.ToLower(fields[1])
+	channel, ok := c.channels[name]
+	return name, ok && !channel.Joined
+}
+
+// releasePending sends the messages held while we were reconnecting, once the server has welcomed us.
+//
+// Messages to channels we are rejoining are kept until we have rejoined them,
+// otherwise the server would refuse them. See releaseChannel.
+func (c *connection) releasePending() {
+	c.mu.Lock()
+	held, dropped := c.pending, c.pendingDropped
+	c.pending, c.pendingDropped = nil, 0
+
+	var msgs []string
+	for _, msg := range held {
+		if name, ok := c.rejoiningChannel(msg); ok {
+			c.channelPending[name] = append(c.channelPending[name], msg)
+		} else {
+			msgs = append(msgs, msg)
+		}
+	}
+	c.mu.Unlock()
+
+	if dropped > 0 {
+		c.pushVarysEvent(EventMessagesDropped, strconv.Itoa(dropped), "too many messages whilst reconnecting")
+	}
+	for _, msg := range msgs {
+		c.send(msg)
+	}
+}
+
+// releaseChannel sends the held messages to a channel, now that we have joined it.
+func (c *connection) releaseChannel(name string) {
+	c.mu.Lock()
+	msgs := c.channelPending[name]
+	delete(c.channelPending, name)
+	c.mu.Unlock()
+
+	for _, msg := range msgs {
+		c.send(msg)
+	}
+}
+
+// dropChannel discards the held messages to a channel, as we aren't going to rejoin it.
+func (c *connection) dropChannel(name string, reason string) {
+	c.mu.Lock()
+	dropped := len(c.channelPending[name])
+	delete(c.channelPending, name)
+	c.mu.Unlock()
+
+	if dropped > 0 {
+		c.pushVarysEvent(EventMessagesDropped, strconv.Itoa(dropped), reason)
+	}
+}
+
+// dropPending discards the messages held while we were reconnecting, as we are never going to send them.
+func (c *connection) dropPending() {
+	c.mu.Lock()
+	dropped := len(c.pending) + c.pendingDropped
+	for _, msgs := range c.channelPending {
+		dropped += len(msgs)
+	}
+	c.pending, c.pendingDropped = nil, 0
+	c.channelPending = make(map[string][]string)
+	c.mu.Unlock()
+
+	if dropped > 0 {
+		c.pushVarysEvent(EventMessagesDropped, strconv.Itoa(dropped), "quit whilst reconnecting")
+	}
+}
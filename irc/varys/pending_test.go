@@ -0,0 +1,70 @@
+package varys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionHold(t *testing.T) {
+	c := newConnection(ConnectParams{Events: []string{EventMessagesDropped}}, RateLimit{})
+
+	// Messages aren't held unless we are connecting or reconnecting
+	c.reconnecting = false
+	c.send("PRIVMSG #go-nuts :hello")
+	assert.Empty(t, c.pending)
+
+	c.reconnecting = true
+	for i := 0; i < maxPendingMessages+2; i++ {
+		c.send("PRIVMSG #go-nuts :hello")
+	}
+	assert.Len(t, c.pending, maxPendingMessages)
+
+	c.reconnecting = false
+	c.releasePending()
+	assert.Empty(t, c.pending)
+
+	events := c.drainEvents()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, []string{"2", "too many messages whilst reconnecting"}, events[0].Arguments)
+	}
+
+	c.reconnecting = true
+	c.send("PRIVMSG #go-nuts :hello")
+	c.dropPending()
+	events = c.drainEvents()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, []string{"1", "quit whilst reconnecting"}, events[0].Arguments)
+	}
+}
+
+func TestConnectionReleaseChannel(t *testing.T) {
+	c := newConnection(ConnectParams{Events: []string{EventMessagesDropped}}, RateLimit{})
+	c.channels["#go-nuts"] = joinedChannel{Name: "#Go-Nuts"}
+	c.channels["#joined"] = joinedChannel{Name: "#joined", Joined: true}
+
+	c.hold("JOIN #Go-Nuts")
+	c.hold("PRIVMSG #Go-Nuts :hello")
+	c.hold("@+draft/reply=1 NOTICE #go-nuts :hello")
+	c.hold("PRIVMSG #joined :hello")
+	c.hold("PRIVMSG qais :hello")
+
+	// Queue the released messages instead of writing them to a socket that isn't open
+	c.reconnecting = false
+	c.limiter = newTokenBucket(RateLimit{Messages: 1, Interval: time.Hour, Burst: 1})
+	c.releasePending()
+	assert.Equal(t, []string{"JOIN #Go-Nuts", "PRIVMSG #joined :hello", "PRIVMSG qais :hello"}, c.queue)
+
+	c.queue = nil
+	c.releaseChannel("#go-nuts")
+	assert.Equal(t, []string{"PRIVMSG #Go-Nuts :hello", "@+draft/reply=1 NOTICE #go-nuts :hello"}, c.queue)
+	assert.Empty(t, c.channelPending)
+
+	c.channelPending["#go-nuts"] = []string{"PRIVMSG #go-nuts :hello"}
+	c.dropChannel("#go-nuts", "gave up rejoining the channel")
+	events := c.drainEvents()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, []string{"1", "gave up rejoining the channel"}, events[0].Arguments)
+	}
+}
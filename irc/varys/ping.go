@@ -0,0 +1,105 @@
+package varys
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// Defaults for SetupParams' ping fields
+const (
+	defaultPingFreq    = 4 * time.Minute
+	defaultPingTimeout = time.Minute
+)
+
+// errPingTimeout is reported when we haven't heard from the server for too long
+var errPingTimeout = errors.New("ping timeout")
+
+func (p SetupParams) pingFreq() time.Duration {
+	if p.PingFreq > 0 {
+		return p.PingFreq
+	}
+	return defaultPingFreq
+}
+
+func (p SetupParams) pingTimeout() time.Duration {
+	if p.PingTimeout > 0 {
+		return p.PingTimeout
+	}
+	return defaultPingTimeout
+}
+
+// activity records when we last heard from the server
+type activity struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (a *activity) touch() {
+	a.mu.Lock()
+	a.last = time.Now()
+	a.mu.Unlock()
+}
+
+func (a *activity) since() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return time.Since(a.last)
+}
+
+// watchActivity records every event we receive on conn.
+func watchActivity(conn *irc.Connection) *activity {
+	a := &activity{last: time.Now()}
+	conn.AddCallback("*", func(e *irc.Event) {
+		a.touch()
+	})
+	return a
+}
+
+// watchdog treats conn as dead if we haven't heard anything from the server
+// (not even a PONG) for a whole ping cycle plus the ping timeout.
+// This catches half-open connections, which would otherwise linger forever.
+//
+// While conn is alive, it also measures the latency every ping timeout.
+//
+// It returns once conn has been replaced, or we have quit.
+func (v *Varys) watchdog(c *connection, conn *irc.Connection, a *activity) {
+	deadline := v.config().pingFreq() + v.config().pingTimeout()
+	ticker := time.NewTicker(v.config().pingTimeout())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		if c.live() != conn {
+			return
+		}
+
+		if a.since() >= deadline {
+			select {
+			case conn.ErrorChan() <- errPingTimeout:
+			default:
+				// The connection has already reported an error
+			}
+			return
+		}
+
+		sendLatencyPing(conn)
+	}
+}
+
+// answerPings replies to the server's PINGs ourselves, rather than relying on the library to.
+//
+// PONGs bypass the rate limit, so a busy queue can't get us disconnected for a ping timeout.
+func answerPings(conn *irc.Connection) {
+	conn.ClearCallback("PING")
+	conn.AddCallback("PING", func(e *irc.Event) {
+		conn.SendRaw("PONG :" + e.Message())
+	})
+}
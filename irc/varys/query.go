@@ -0,0 +1,140 @@
+package varys
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// queryTimeout is how long we wait for the server to finish replying to a query
+const queryTimeout = 10 * time.Second
+
+// errNotConnected is returned when a query can't be sent because the connection doesn't exist
+var errNotConnected = errors.New("no such connection")
+
+// queries correlates replies from the server with the queries that caused them.
+//
+// Queries are keyed by what they are about (such as "whois nick"), so concurrent
+// queries for the same thing share a single request and result.
+type queries struct {
+	mu      sync.Mutex
+	pending map[string]*query
+}
+
+type query struct {
+	value   interface{}
+	err     error
+	waiters []chan *query
+}
+
+// queryKey returns the key for a query, folding the subject's case.
+func queryKey(kind string, subject string) string {
+	return kind + " " + strings.ToLower(subject)
+}
+
+// start registers interest in the query key, returning a channel that receives the
+// finished query. If this is a new query, value is its initial value, and send is called.
+func (q *queries) start(key string, value interface{}, send func()) <-chan *query {
+	result := make(chan *query, 1)
+
+	q.mu.Lock()
+	if q.pending == nil {
+		q.pending = make(map[string]*query)
+	}
+	p, ok := q.pending[key]
+	if !ok {
+		p = &query{value: value}
+		q.pending[key] = p
+	}
+	p.waiters = append(p.waiters, result)
+	q.mu.Unlock()
+
+	if !ok {
+		send()
+	}
+	return result
+}
+
+// update calls fn with the value of the query key, if we are waiting for it.
+func (q *queries) update(key string, fn func(value interface{})) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if p, ok := q.pending[key]; ok {
+		fn(p.value)
+	}
+}
+
+// finish completes the query key, failing it if err is not nil.
+func (q *queries) finish(key string, err error) {
+	q.mu.Lock()
+	p, ok := q.pending[key]
+	delete(q.pending, key)
+	q.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	p.err = err
+	for _, w := range p.waiters {
+		w <- p
+	}
+}
+
+// finishPrefix completes every query whose key starts with prefix, failing them if err is not nil.
+func (q *queries) finishPrefix(prefix string, err error) {
+	var keys []string
+	q.mu.Lock()
+	for key := range q.pending {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, key := range keys {
+		q.finish(key, err)
+	}
+}
+
+// cancel gives up on the query key, if it's still pending:
+// another query for the same thing will resend the request.
+func (q *queries) cancel(key string) {
+	q.mu.Lock()
+	delete(q.pending, key)
+	q.mu.Unlock()
+}
+
+// wait waits for a query to finish, giving up after timeout.
+func (q *queries) wait(key string, result <-chan *query, timeout time.Duration) (interface{}, error) {
+	select {
+	case p := <-result:
+		return p.value, p.err
+	case <-time.After(timeout):
+		q.cancel(key)
+		return nil, errors.New("timed out waiting for the server to reply to " + key)
+	}
+}
+
+// queryConn returns the connection for uid to send a query with.
+// If uid is blank, any connected connection is used.
+func (v *Varys) queryConn(uid string) (*connection, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if uid != "" {
+		if c, ok := v.uidToConns[uid]; ok {
+			return c, nil
+		}
+		return nil, errNotConnected
+	}
+
+	for _, c := range v.uidToConns {
+		if c.live() != nil {
+			return c, nil
+		}
+	}
+	return nil, errNotConnected
+}
@@ -0,0 +1,74 @@
+package varys
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueriesShareRequest(t *testing.T) {
+	var q queries
+	sent := 0
+	send := func() { sent++ }
+
+	key := queryKey("whois", "Nick")
+	first := q.start(key, &whois{}, send)
+	second := q.start(queryKey("whois", "nick"), &whois{}, send)
+	assert.Equal(t, 1, sent)
+
+	q.update(key, func(value interface{}) {
+		value.(*whois).result.Account = "account"
+	})
+	q.finish(key, nil)
+
+	for _, result := range []<-chan *query{first, second} {
+		value, err := q.wait(key, result, time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, "account", value.(*whois).result.Account)
+	}
+}
+
+func TestQueriesFinishError(t *testing.T) {
+	var q queries
+	key := queryKey("whois", "nick")
+	result := q.start(key, &whois{}, func() {})
+
+	errMissing := errors.New("missing")
+	q.finish(key, errMissing)
+
+	_, err := q.wait(key, result, time.Second)
+	assert.ErrorIs(t, err, errMissing)
+}
+
+func TestQueriesTimeout(t *testing.T) {
+	var q queries
+	sent := 0
+	key := queryKey("whois", "nick")
+
+	_, err := q.wait(key, q.start(key, &whois{}, func() { sent++ }), time.Millisecond)
+	assert.Error(t, err)
+
+	// The timed out query is forgotten, so the next one resends the request
+	q.start(key, &whois{}, func() { sent++ })
+	assert.Equal(t, 2, sent)
+}
+
+func TestQueriesFinishPrefix(t *testing.T) {
+	var q queries
+	kick := q.start(queryKey("kick", "#channel nick"), nil, func() {})
+	ban := q.start(queryKey("kick", "#channel other"), nil, func() {})
+	other := q.start(queryKey("kick", "#other nick"), nil, func() {})
+
+	errPrivs := errors.New("not an operator")
+	q.finishPrefix(queryKey("kick", "#Channel")+" ", errPrivs)
+
+	for _, result := range []<-chan *query{kick, ban} {
+		_, err := q.wait("", result, time.Second)
+		assert.ErrorIs(t, err, errPrivs)
+	}
+	_, err := q.wait(queryKey("kick", "#other nick"), other, time.Millisecond)
+	assert.Error(t, err)
+	assert.NotEqual(t, errPrivs, err)
+}
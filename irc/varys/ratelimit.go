@@ -0,0 +1,309 @@
+package varys
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// RateLimit is a token bucket limit on how quickly messages are sent.
+//
+// The rate backs off if the server says we are sending too quickly, and recovers gradually.
+type RateLimit struct {
+	Messages int           // Messages is the number of messages allowed per Interval
+	Interval time.Duration // Leave zero to disable rate limiting
+	Burst    int           // Burst is the number of messages that can be sent at once
+}
+
+// The rate limit backs off when the server signals that we are being throttled,
+// halving the rate each time down to minRateFactor of the configured rate.
+// It then recovers to the configured rate over rateRecovery.
+const (
+	minRateFactor = 1.0 / 8
+	rateRecovery  = time.Minute
+
+	// slowWrite is how long a write can block before we treat it as a sign of being throttled
+	slowWrite = time.Second
+)
+
+// throttleNotices are phrases in server notices that tell us we are sending too quickly
+var throttleNotices = []string{"throttled", "flooding", "too fast", "slow down", "sendq"}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	base   float64 // the configured rate, in tokens per second
+	rate   float64 // the current rate, which is lower than base after backing off
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket for the limit, or nil if it does not limit anything.
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	if limit.Messages <= 0 || limit.Interval <= 0 {
+		return nil
+	}
+
+	burst := limit.Burst
+	if burst < 1 {
+		burst = 1
+	}
+
+	rate := float64(limit.Messages) / limit.Interval.Seconds()
+	return &tokenBucket{
+		base:   rate,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token, returning how long to wait before it may be used.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens += elapsed * b.rate
+	b.rate += elapsed * b.base / rateRecovery.Seconds()
+	if b.rate > b.base {
+		b.rate = b.base
+	}
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// backOff halves the rate, and gives up any burst we had saved.
+func (b *tokenBucket) backOff() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rate /= 2
+	if min := b.base * minRateFactor; b.rate < min {
+		b.rate = min
+	}
+	if b.tokens > 0 {
+		b.tokens = 0
+	}
+}
+
+// currentRate returns how many tokens are added per second.
+func (b *tokenBucket) currentRate() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rate
+}
+
+// isThrottleNotice returns whether msg is the server telling us to slow down.
+func isThrottleNotice(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, phrase := range throttleNotices {
+		if strings.Contains(msg, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchThrottling backs off the rate limit when the server says we are sending too quickly.
+func (c *connection) watchThrottling(conn *irc.Connection) {
+	if c.limiter == nil {
+		return
+	}
+
+	conn.AddCallback("NOTICE", func(e *irc.Event) {
+		// Only the server can throttle us, so users can't slow us down
+		if !strings.Contains(e.Source, "!") && isThrottleNotice(e.Message()) {
+			c.limiter.backOff()
+		}
+	})
+
+	// RPL_TRYAGAIN
+	conn.AddCallback("263", func(e *irc.Event) {
+		c.limiter.backOff()
+	})
+}
+
+// send sends msg immediately, or queues it for sendLoop if we are rate limited.
+//
+// Messages are held if we are reconnecting, and sent once we are back.
+func (c *connection) send(msg string) {
+	if c.limiter == nil {
+		if conn := c.live(); conn != nil {
+			conn.SendRaw(msg)
+		} else {
+			c.hold(msg)
+		}
+		return
+	}
+
+	c.mu.Lock()
+	c.queue = append(c.queue, msg)
+	c.mu.Unlock()
+
+	select {
+	case c.queued <- struct{}{}:
+	default:
+	}
+}
+
+// sendLoop drains the queue at the allowed rate, until we quit.
+func (c *connection) sendLoop() {
+	defer func() {
+		c.mu.Lock()
+		c.queue = nil
+		c.sending = false
+		c.mu.Unlock()
+	}()
+
+	for {
+		c.mu.Lock()
+		var msg string
+		ok := len(c.queue) > 0
+		if ok {
+			msg = c.queue[0]
+			c.queue = c.queue[1:]
+			c.sending = true
+		}
+		c.mu.Unlock()
+
+		if !ok {
+			select {
+			case <-c.done:
+				return
+			case <-c.queued:
+			}
+			continue
+		}
+
+		if wait := c.limiter.reserve(time.Now()); wait > 0 {
+			select {
+			case <-c.done:
+				return
+			case <-time.After(wait):
+			}
+		}
+
+		if conn := c.live(); conn != nil {
+			// Writes block once the library's buffer is full, which means the server isn't keeping up
+			start := time.Now()
+			conn.SendRaw(msg)
+			if time.Since(start) >= slowWrite {
+				c.limiter.backOff()
+			}
+		} else {
+			c.hold(msg)
+		}
+
+		c.mu.Lock()
+		c.sending = false
+		c.mu.Unlock()
+	}
+}
+
+// drain waits for queued messages to be sent, giving up after timeout.
+// It returns false if messages were still queued.
+func (c *connection) drain(timeout time.Duration) bool {
+	if c.limiter == nil {
+		return true
+	}
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		c.mu.Lock()
+		drained := len(c.queue) == 0 && !c.sending
+		c.mu.Unlock()
+
+		if drained {
+			return true
+		}
+
+		select {
+		case <-c.done:
+			return false
+		case <-deadline:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// queueDepth returns how many messages are waiting to be sent.
+func (c *connection) queueDepth() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.queue)
+}
+
+// flush empties the queue, sending its messages immediately unless drop is true.
+// It returns how many messages were flushed.
+//
+// If we are reconnecting, the messages are held to send once we are back, like any others.
+func (c *connection) flush(drop bool) int {
+	c.mu.Lock()
+	msgs := c.queue
+	c.queue = nil
+	c.mu.Unlock()
+
+	if drop {
+		return len(msgs)
+	}
+
+	conn := c.live()
+	for _, msg := range msgs {
+		if conn != nil {
+			conn.SendRaw(msg)
+		} else {
+			c.hold(msg)
+		}
+	}
+	return len(msgs)
+}
+
+// GetQueueDepth returns how many messages are waiting to be sent because of rate limiting.
+// A blank UID totals every connection.
+func (v *Varys) GetQueueDepth(uid string, result *int) error {
+	depth := 0
+	v.connCall(uid, func(c *connection) {
+		depth += c.queueDepth()
+	})
+	*result = depth
+	return nil
+}
+
+type FlushQueueParams struct {
+	UID string
+
+	// Drop discards the queued messages, instead of sending them
+	Drop bool
+}
+
+// FlushQueue empties the queue of messages waiting because of rate limiting, returning how many were flushed.
+// A blank UID flushes every connection.
+//
+// Unless dropped, the messages are sent immediately, ignoring the rate limit,
+// so flushing a long queue may get us disconnected for flooding.
+func (v *Varys) FlushQueue(params FlushQueueParams, result *int) error {
+	flushed := 0
+	v.connCall(params.UID, func(c *connection) {
+		flushed += c.flush(params.Drop)
+	})
+	if result != nil {
+		*result = flushed
+	}
+	return nil
+}
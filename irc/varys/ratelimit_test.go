@@ -0,0 +1,90 @@
+package varys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketDisabled(t *testing.T) {
+	assert.True(t, newTokenBucket(RateLimit{}) == nil)
+	assert.True(t, newTokenBucket(RateLimit{Messages: 1}) == nil)
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	b := newTokenBucket(RateLimit{Messages: 1, Interval: time.Second, Burst: 2})
+	now := b.last
+
+	// The burst is available immediately
+	assert.Equal(t, time.Duration(0), b.reserve(now))
+	assert.Equal(t, time.Duration(0), b.reserve(now))
+
+	// After that, each message waits for a token
+	assert.Equal(t, time.Second, b.reserve(now))
+	assert.Equal(t, 2*time.Second, b.reserve(now))
+
+	// Tokens refill over time, but never beyond the burst
+	b = newTokenBucket(RateLimit{Messages: 1, Interval: time.Second, Burst: 2})
+	now = b.last.Add(time.Hour)
+	assert.Equal(t, time.Duration(0), b.reserve(now))
+	assert.Equal(t, time.Duration(0), b.reserve(now))
+	assert.Equal(t, time.Second, b.reserve(now))
+}
+
+func TestConnectionDrain(t *testing.T) {
+	c := newConnection(ConnectParams{}, RateLimit{Messages: 100, Interval: time.Second, Burst: 1})
+	for i := 0; i < 3; i++ {
+		c.send("PRIVMSG #channel :hello")
+	}
+
+	// Nothing is sending the queue yet
+	assert.False(t, c.drain(10*time.Millisecond))
+
+	go c.sendLoop()
+	assert.True(t, c.drain(time.Second))
+
+	close(c.done)
+}
+
+func TestConnectionFlush(t *testing.T) {
+	c := newConnection(ConnectParams{}, RateLimit{Messages: 1, Interval: time.Hour, Burst: 1})
+	for i := 0; i < 3; i++ {
+		c.send("PRIVMSG #channel :hello")
+	}
+	assert.Equal(t, 3, c.queueDepth())
+
+	assert.Equal(t, 3, c.flush(true))
+	assert.Equal(t, 0, c.queueDepth())
+	assert.True(t, c.drain(time.Second))
+	assert.Empty(t, c.pending)
+
+	// Messages flushed before we are connected are held, not lost
+	c.send("PRIVMSG #channel :hello")
+	assert.Equal(t, 1, c.flush(false))
+	assert.Equal(t, 0, c.queueDepth())
+	assert.Equal(t, []string{"PRIVMSG #channel :hello"}, c.pending)
+}
+
+func TestTokenBucketBackOff(t *testing.T) {
+	b := newTokenBucket(RateLimit{Messages: 8, Interval: time.Second, Burst: 4})
+	now := b.last
+
+	b.backOff()
+	assert.Equal(t, 4.0, b.currentRate())
+	assert.Equal(t, 250*time.Millisecond, b.reserve(now), "the burst should be given up")
+
+	for i := 0; i < 5; i++ {
+		b.backOff()
+	}
+	assert.Equal(t, 1.0, b.currentRate(), "the rate shouldn't drop below the minimum")
+
+	b.reserve(now.Add(rateRecovery))
+	assert.Equal(t, 8.0, b.currentRate(), "the rate should recover")
+}
+
+func TestIsThrottleNotice(t *testing.T) {
+	assert.True(t, isThrottleNotice("*** Message to #go-nuts throttled due to flooding"))
+	assert.True(t, isThrottleNotice("You are sending messages too fast"))
+	assert.False(t, isThrottleNotice("*** Looking up your hostname..."))
+}
@@ -0,0 +1,51 @@
+package varys
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// realName returns the realname for a connection using nick.
+//
+// If there's a RealNameTemplate, ${UID}, ${NICK} and ${NAME} are replaced
+// with the UID, nick and display name. Otherwise the connection's RealName is used.
+//
+// Realnames from the template are cut short to RealNameLength, or else serverMax
+// (the server's NAMELEN), if either is set, as the template can make them longer than expected.
+func (p SetupParams) realName(params ConnectParams, nick string, serverMax int) string {
+	if p.RealNameTemplate == "" {
+		return params.RealName
+	}
+
+	name := params.DisplayName
+	if name == "" {
+		name = params.RealName
+	}
+
+	realName := strings.NewReplacer(
+		"${UID}", params.UID,
+		"${NICK}", nick,
+		"${NAME}", name,
+	).Replace(p.RealNameTemplate)
+
+	max := p.RealNameLength
+	if max <= 0 {
+		max = serverMax
+	}
+	if max > 0 {
+		realName = truncate(realName, max)
+	}
+	return realName
+}
+
+// truncate shortens s to at most max bytes, without splitting a rune.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,32 @@
+package varys
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealName(t *testing.T) {
+	params := ConnectParams{UID: "1234", RealName: "qaisjp"}
+
+	assert.Equal(t, "qaisjp", SetupParams{}.realName(params, "qais~d", 0))
+	assert.Equal(t, "qais~d via Discord (qaisjp, 1234)",
+		SetupParams{RealNameTemplate: "${NICK} via Discord (${NAME}, ${UID})"}.realName(params, "qais~d", 0))
+
+	params.DisplayName = "Qais"
+	assert.Equal(t, "Qais", SetupParams{RealNameTemplate: "${NAME}"}.realName(params, "qais~d", 0))
+
+	// Long realnames from the template are cut short, without splitting runes
+	params.DisplayName = strings.Repeat("a", 49) + "é"
+	template := SetupParams{RealNameTemplate: "${NAME}"}
+	assert.Equal(t, params.DisplayName, template.realName(params, "qais~d", 0), "there's no limit unless we know one")
+	assert.Equal(t, strings.Repeat("a", 49), template.realName(params, "qais~d", 50))
+
+	template.RealNameLength = 10
+	assert.Equal(t, strings.Repeat("a", 10), template.realName(params, "qais~d", 50))
+
+	// Realnames without a template are left for the server to handle
+	params.RealName = strings.Repeat("a", 100)
+	assert.Equal(t, params.RealName, SetupParams{}.realName(params, "qais~d", 50))
+}
@@ -0,0 +1,118 @@
+package varys
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// defaultConnectTimeout is how long we wait to connect and be welcomed by the server
+const defaultConnectTimeout = 30 * time.Second
+
+func (p ConnectParams) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return defaultConnectTimeout
+}
+
+// registration reports whether a connection managed to register with the server.
+type registration struct {
+	result chan error
+}
+
+// watchRegistration succeeds the registration once the server welcomes us.
+// Other callbacks can fail the registration by reporting an error.
+//
+// If identify is not blank, it is sent once we are welcomed, and we wait
+// for NickServ to identify us (or nickServTimeout) before succeeding.
+func watchRegistration(conn *irc.Connection, identify string) *registration {
+	reg := &registration{result: make(chan error, 1)}
+
+	// Servers send ERROR before closing the link, such as when we are banned.
+	// Once we are welcomed this is too late to count, and the connection is reestablished as normal.
+	conn.AddCallback("ERROR", func(e *irc.Event) {
+		reg.report(fmt.Errorf("%w: %s", ErrRejected, e.Message()))
+	})
+
+	// ERR_YOUREBANNEDCREEP comes before the ERROR, and says why we are banned (such as a K-line)
+	conn.AddCallback("465", func(e *irc.Event) {
+		reg.report(fmt.Errorf("%w: %s", ErrBanned, e.Message()))
+	})
+
+	if identify == "" {
+		conn.AddCallback("001", func(e *irc.Event) {
+			reg.report(nil)
+		})
+		return reg
+	}
+
+	identified := watchIdentified(conn)
+	conn.AddCallback("001", func(e *irc.Event) {
+		conn.SendRaw(identify)
+		go func() {
+			select {
+			case <-identified:
+			case <-time.After(nickServTimeout):
+			}
+			reg.report(nil)
+		}()
+	})
+	return reg
+}
+
+// report records the outcome of registration. Only the first outcome counts.
+func (r *registration) report(err error) {
+	select {
+	case r.result <- err:
+	default:
+	}
+}
+
+// connectContext opens the connection, returning early if ctx is done.
+//
+// If we give up, but the connection is opened later on, it is disconnected.
+func connectContext(ctx context.Context, conn *irc.Connection, server string) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- conn.Connect(server)
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			return classifyDialError(err, conn.UseSASL)
+		}
+		return nil
+	case <-ctx.Done():
+		go func() {
+			if err := <-result; err == nil {
+				conn.Disconnect()
+			}
+		}()
+		return fmt.Errorf("%w to the server", ErrConnectTimeout)
+	}
+}
+
+// waitForIRCConnection blocks until the server has welcomed us,
+// registration has failed, the connection has dropped, or ctx is done.
+func waitForIRCConnection(ctx context.Context, reg *registration, dropped <-chan error) error {
+	select {
+	case err := <-reg.result:
+		return err
+	case err := <-dropped:
+		// The server usually tells us why with ERROR first
+		select {
+		case reason := <-reg.result:
+			if reason != nil {
+				return reason
+			}
+		default:
+		}
+		return fmt.Errorf("%w: %s", ErrRejected, err)
+	case <-ctx.Done():
+		return fmt.Errorf("%w: the server didn't welcome us in time", ErrConnectTimeout)
+	}
+}
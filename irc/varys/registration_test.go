@@ -0,0 +1,34 @@
+package varys
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForIRCConnectionDropped(t *testing.T) {
+	ctx := context.Background()
+
+	// Dropped without a reason
+	reg := &registration{result: make(chan error, 1)}
+	dropped := make(chan error, 1)
+	dropped <- io.EOF
+	err := waitForIRCConnection(ctx, reg, dropped)
+	assert.ErrorIs(t, err, ErrRejected)
+	assert.Contains(t, err.Error(), "EOF")
+
+	// The server's ERROR is preferred, as it says why
+	reg = &registration{result: make(chan error, 1)}
+	reason := fmt.Errorf("%w: Closing Link: K-Lined", ErrRejected)
+	reg.report(reason)
+	dropped <- io.EOF
+	assert.Equal(t, reason, waitForIRCConnection(ctx, reg, dropped))
+
+	// Welcomed
+	reg = &registration{result: make(chan error, 1)}
+	reg.report(nil)
+	assert.NoError(t, waitForIRCConnection(ctx, reg, make(chan error)))
+}
@@ -0,0 +1,183 @@
+package varys
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// ErrReauthUnsupported is returned by Reauth when the server doesn't let us authenticate again once registered
+var ErrReauthUnsupported = errors.New("server does not support sasl reauthentication")
+
+// maxAuthenticateLength is the longest AUTHENTICATE payload that fits on one line
+const maxAuthenticateLength = 400
+
+// watchSASL fails the registration if the server rejects our SASL credentials.
+//
+// Errors never include the password.
+func watchSASL(conn *irc.Connection, reg *registration) {
+	conn.AddCallback("904", func(e *irc.Event) {
+		if conn.SASLMech == "EXTERNAL" {
+			reg.report(fmt.Errorf("%w: server rejected our client certificate: %s", ErrSASLFailed, e.Message()))
+			return
+		}
+		reg.report(fmt.Errorf("%w for %q: %s", ErrSASLFailed, conn.SASLLogin, e.Message()))
+	})
+	conn.AddCallback("905", func(e *irc.Event) {
+		reg.report(fmt.Errorf("%w for %q: sasl message too long: %s", ErrSASLFailed, conn.SASLLogin, e.Message()))
+	})
+}
+
+// pickSASLMech returns the SASL mechanism to use, out of those the server advertised, such as "PLAIN,EXTERNAL".
+// EXTERNAL is preferred to PLAIN. If the server didn't say, we use the one we prefer.
+func pickSASLMech(advertised string, external bool, plain bool) (string, bool) {
+	supports := func(mech string) bool {
+		return advertised == "" || containsFold(strings.Split(advertised, ","), mech)
+	}
+
+	switch {
+	case external && supports("EXTERNAL"):
+		return "EXTERNAL", true
+	case plain && supports("PLAIN"):
+		return "PLAIN", true
+	}
+	return "", false
+}
+
+// chooseSASLMech picks the SASL mechanism to use on conn, once the server has said which it supports.
+// Registration fails if none of them are ones we can use.
+//
+// go-ircevent sends a plain CAP LS, not CAP LS 302, and we can't send our own: its CAP handling
+// only understands single-line replies without values. So most servers don't list their mechanisms,
+// and then we use the one we prefer, finding out whether the server supports it from its reply to AUTHENTICATE.
+func chooseSASLMech(conn *irc.Connection, reg *registration, external bool, plain bool) {
+	conn.SASLMech, _ = pickSASLMech("", external, plain)
+
+	// The mechanism is only sent once the server acknowledges the sasl cap, which is after CAP LS
+	conn.AddCallback("CAP", func(e *irc.Event) {
+		mech, err := saslMechFromCapLS(e.Arguments, external, plain)
+		if err != nil {
+			reg.report(err)
+			return
+		}
+		if mech != "" {
+			conn.SASLMech = mech
+		}
+	})
+}
+
+// saslMechFromCapLS picks the SASL mechanism to use from a CAP LS reply.
+// It returns a blank mechanism if the reply doesn't mention sasl.
+func saslMechFromCapLS(args []string, external bool, plain bool) (string, error) {
+	caps, _ := parseCapLS(args)
+	advertised, ok := caps["sasl"]
+	if !ok {
+		return "", nil
+	}
+
+	mech, ok := pickSASLMech(advertised, external, plain)
+	if !ok {
+		return "", fmt.Errorf("%w: server only supports %s", ErrSASLFailed, advertised)
+	}
+	return mech, nil
+}
+
+// authenticateLines splits a SASL response into AUTHENTICATE lines.
+// A response that fills the last line exactly is followed by "+", as is an empty response.
+func authenticateLines(response []byte) []string {
+	encoded := base64.StdEncoding.EncodeToString(response)
+
+	var lines []string
+	for len(encoded) >= maxAuthenticateLength {
+		lines = append(lines, "AUTHENTICATE "+encoded[:maxAuthenticateLength])
+		encoded = encoded[maxAuthenticateLength:]
+	}
+	if encoded == "" {
+		encoded = "+"
+	}
+	return append(lines, "AUTHENTICATE "+encoded)
+}
+
+// saslResponse returns our response to the server's challenge for the mechanism used on conn.
+func saslResponse(conn *irc.Connection) []byte {
+	if conn.SASLMech == "EXTERNAL" {
+		// The certificate is all the server needs
+		return nil
+	}
+	return []byte(conn.SASLLogin + "\x00" + conn.SASLLogin + "\x00" + conn.SASLPassword)
+}
+
+// handleReauth lets Reauth authenticate again once registered, as in IRCv3 SASL 3.2.
+//
+// go-ircevent's SASL callbacks are only meant for registration, and can quit or hang
+// the connection if they see a later exchange, so they are replaced once registered.
+func (c *connection) handleReauth(conn *irc.Connection) {
+	key := queryKey("reauth", "")
+
+	for _, code := range []string{"AUTHENTICATE", "901", "902", "903", "904", "905", "906", "907", "908"} {
+		conn.ClearCallback(code)
+	}
+
+	// AUTHENTICATE + asks for our response
+	conn.AddCallback("AUTHENTICATE", func(e *irc.Event) {
+		pending := false
+		c.queries.update(key, func(value interface{}) {
+			pending = true
+		})
+		if pending && len(e.Arguments) > 0 && e.Arguments[0] == "+" {
+			for _, line := range authenticateLines(saslResponse(conn)) {
+				c.send(line)
+			}
+		}
+	})
+
+	// RPL_SASLSUCCESS <me> :SASL authentication successful
+	conn.AddCallback("903", func(e *irc.Event) {
+		c.queries.finish(key, nil)
+	})
+
+	fail := func(err error) func(e *irc.Event) {
+		return func(e *irc.Event) {
+			c.queries.finish(key, fmt.Errorf("%w for %q: %s", err, conn.SASLLogin, e.Message()))
+		}
+	}
+	conn.AddCallback("902", fail(ErrSASLFailed)) // ERR_NICKLOCKED
+	conn.AddCallback("904", fail(ErrSASLFailed)) // ERR_SASLFAIL
+	conn.AddCallback("905", fail(ErrSASLFailed)) // ERR_SASLTOOLONG
+	conn.AddCallback("906", fail(ErrSASLFailed)) // ERR_SASLABORTED
+	// ERR_SASLALREADY is sent by servers that only let us authenticate once
+	conn.AddCallback("907", fail(ErrReauthUnsupported))
+}
+
+// Reauth authenticates the connection again using SASL, with the credentials it connected with,
+// without reconnecting. This needs the server to support the sasl capability.
+//
+// Reconnecting always authenticates again, so this is only needed if the server logged us out.
+func (v *Varys) Reauth(uid string, _ *struct{}) error {
+	c, err := v.queryConn(uid)
+	if err != nil {
+		return err
+	}
+
+	conn := c.live()
+	if conn == nil {
+		return errNotConnected
+	}
+	if !conn.UseSASL {
+		return errors.New("connection does not use sasl")
+	}
+	if !hasCap(conn, "sasl") {
+		return ErrReauthUnsupported
+	}
+
+	key := queryKey("reauth", "")
+	wait := c.queries.start(key, nil, func() {
+		c.send("AUTHENTICATE " + conn.SASLMech)
+	})
+
+	_, err = c.queries.wait(key, wait, queryTimeout)
+	return err
+}
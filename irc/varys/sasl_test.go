@@ -0,0 +1,68 @@
+package varys
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticateLines(t *testing.T) {
+	assert.Equal(t, []string{"AUTHENTICATE +"}, authenticateLines(nil))
+	assert.Equal(t, []string{"AUTHENTICATE dXNlcgB1c2VyAHBhc3M="}, authenticateLines([]byte("user\x00user\x00pass")))
+
+	// 300 bytes encode to exactly 400 characters, so an empty line follows
+	lines := authenticateLines([]byte(strings.Repeat("a", 300)))
+	assert.Len(t, lines, 2)
+	assert.Len(t, lines[0], len("AUTHENTICATE ")+maxAuthenticateLength)
+	assert.Equal(t, "AUTHENTICATE +", lines[1])
+
+	lines = authenticateLines([]byte(strings.Repeat("a", 301)))
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "AUTHENTICATE YQ==", lines[1])
+}
+
+func TestPickSASLMech(t *testing.T) {
+	tests := []struct {
+		advertised string
+		external   bool
+		plain      bool
+		expected   string
+	}{
+		{"", true, true, "EXTERNAL"},
+		{"", false, true, "PLAIN"},
+		{"PLAIN,EXTERNAL", true, true, "EXTERNAL"},
+		{"PLAIN", true, true, "PLAIN"},
+		{"EXTERNAL,SCRAM-SHA-256", false, true, ""},
+		{"external", true, false, "EXTERNAL"},
+	}
+
+	for _, test := range tests {
+		mech, ok := pickSASLMech(test.advertised, test.external, test.plain)
+		assert.Equal(t, test.expected, mech, test)
+		assert.Equal(t, test.expected != "", ok, test)
+	}
+}
+
+func TestSASLMechFromCapLS(t *testing.T) {
+	// A plain CAP LS lists sasl without its mechanisms
+	mech, err := saslMechFromCapLS([]string{"*", "LS", "multi-prefix sasl"}, true, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "EXTERNAL", mech)
+
+	mech, err = saslMechFromCapLS([]string{"*", "LS", "multi-prefix sasl"}, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "PLAIN", mech)
+
+	// CAP LS 302 lists them
+	mech, err = saslMechFromCapLS([]string{"*", "LS", "sasl=PLAIN multi-prefix"}, true, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "PLAIN", mech)
+
+	_, err = saslMechFromCapLS([]string{"*", "LS", "sasl=SCRAM-SHA-256"}, true, true)
+	assert.ErrorIs(t, err, ErrSASLFailed)
+
+	mech, err = saslMechFromCapLS([]string{"*", "LS", "multi-prefix"}, true, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "", mech)
+}
@@ -1,7 +1,11 @@
 package varys
 
-func NewServer() {
-	// varys := new(Varys)
+import "github.com/sirupsen/logrus"
+
+// NewServer will serve Varys over net/rpc, logging to logger.
+// If logger is nil, logrus's standard logger is used.
+func NewServer(logger *logrus.Logger) {
+	// varys := NewVarys(logger)
 	// rpc.Register(varys)
 	// rpc.HandleHTTP()
 	// l, e := net.Listen("tcp", ":1234")
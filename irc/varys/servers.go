@@ -0,0 +1,39 @@
+package varys
+
+import (
+	"hash/fnv"
+)
+
+// servers returns the pool of servers to spread connections across.
+func (p SetupParams) servers() []string {
+	if len(p.Servers) > 0 {
+		return p.Servers
+	}
+	return []string{p.Server}
+}
+
+// pickServer returns the server c should connect to next.
+//
+// Connections are spread across the pool by UID. If failed is true,
+// the previous attempt failed, so we move on to the next server in the pool.
+func (v *Varys) pickServer(c *connection, failed bool) string {
+	if c.params.Server != "" {
+		return c.params.Server
+	}
+
+	pool := v.config().servers()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.server == "" {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(c.params.UID))
+		c.serverIndex = int(h.Sum32() % uint32(len(pool)))
+	} else if failed {
+		c.serverIndex = (c.serverIndex + 1) % len(pool)
+	}
+
+	c.server = pool[c.serverIndex%len(pool)]
+	return c.server
+}
@@ -0,0 +1,112 @@
+package varys
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// defaultServiceQuietPeriod is how long a service must stay quiet before we consider its reply complete
+const defaultServiceQuietPeriod = 2 * time.Second
+
+// maxServiceReplyLines is how many reply lines we buffer for a service query
+const maxServiceReplyLines = 200
+
+// serviceQuery collects the NOTICEs a service sends in reply to a command
+type serviceQuery struct {
+	service string
+	lines   chan string
+}
+
+// handleServiceReplies passes NOTICEs from services to the pending service query.
+func (c *connection) handleServiceReplies(conn *irc.Connection) {
+	conn.AddCallback("NOTICE", func(e *irc.Event) {
+		c.mu.Lock()
+		q := c.serviceQuery
+		c.mu.Unlock()
+
+		if q == nil || !strings.EqualFold(e.Nick, q.service) {
+			return
+		}
+		select {
+		case q.lines <- c.encoding().decode(e.Message()):
+		default:
+			// The reply is longer than we are willing to buffer
+		}
+	})
+}
+
+type ServiceQueryParams struct {
+	UID     string
+	Service string
+	Command string
+
+	// QuietPeriod is how long the service must stay quiet before its reply is complete. Defaults to 2 seconds.
+	QuietPeriod time.Duration
+}
+
+func (p ServiceQueryParams) quietPeriod() time.Duration {
+	if p.QuietPeriod > 0 {
+		return p.QuietPeriod
+	}
+	return defaultServiceQuietPeriod
+}
+
+// ServiceQuery sends a command to a service, such as "INFO #channel" to ChanServ,
+// and returns the NOTICEs it replies with. If the UID is blank, any connection is used.
+//
+// Services don't say when they have finished replying, so the reply is complete once
+// the service has been quiet for the QuietPeriod. Queries on the same connection take turns,
+// so that their replies aren't mixed up.
+func (v *Varys) ServiceQuery(params ServiceQueryParams, result *[]string) error {
+	msg, err := formatMessage("PRIVMSG", params.Service, params.Command)
+	if err != nil {
+		return err
+	}
+
+	c, err := v.queryConn(params.UID)
+	if err != nil {
+		return err
+	}
+
+	c.serviceMu.Lock()
+	defer c.serviceMu.Unlock()
+
+	q := &serviceQuery{service: params.Service, lines: make(chan string, maxServiceReplyLines)}
+	c.mu.Lock()
+	c.serviceQuery = q
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.serviceQuery = nil
+		c.mu.Unlock()
+	}()
+
+	c.sendSplit(msg, nil)
+
+	// Wait as long as any other query for the first line, and then for the service to go quiet
+	timer := time.NewTimer(queryTimeout)
+	defer timer.Stop()
+
+	var lines []string
+	for {
+		select {
+		case line := <-q.lines:
+			lines = append(lines, line)
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(params.quietPeriod())
+		case <-timer.C:
+			if len(lines) == 0 {
+				return errors.New("timed out waiting for " + params.Service + " to reply")
+			}
+			*result = lines
+			return nil
+		case <-c.done:
+			return errNotConnected
+		}
+	}
+}
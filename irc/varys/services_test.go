@@ -0,0 +1,37 @@
+package varys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceQuery(t *testing.T) {
+	v := NewVarys(nil)
+	c := newConnection(ConnectParams{UID: "123"}, RateLimit{})
+	v.add(c)
+
+	go func() {
+		for {
+			c.mu.Lock()
+			q := c.serviceQuery
+			c.mu.Unlock()
+			if q != nil {
+				q.lines <- "Information on #go-nuts:"
+				q.lines <- "Founder: qaisjp"
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	var lines []string
+	params := ServiceQueryParams{UID: "123", Service: "ChanServ", Command: "INFO #go-nuts", QuietPeriod: 20 * time.Millisecond}
+	assert.NoError(t, v.ServiceQuery(params, &lines))
+	assert.Equal(t, []string{"Information on #go-nuts:", "Founder: qaisjp"}, lines)
+	assert.Nil(t, c.serviceQuery)
+
+	params.Service = "Chan Serv"
+	assert.Error(t, v.ServiceQuery(params, &lines))
+}
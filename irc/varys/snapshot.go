@@ -0,0 +1,145 @@
+package varys
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// snapshotVersion is the version of the snapshot format written by SaveSnapshot
+const snapshotVersion = 1
+
+// snapshot is the state of every connection, so that they can be restored after restarting.
+//
+// Snapshots include credentials, so they are only readable by their owner.
+type snapshot struct {
+	Version     int
+	Connections []connectionSnapshot
+}
+
+type connectionSnapshot struct {
+	// Params are what the connection was made with. Callbacks are not saved.
+	Params ConnectParams
+
+	Nick     string
+	Channels []channelSnapshot
+	Away     string
+}
+
+type channelSnapshot struct {
+	Name string
+	Key  string
+}
+
+func (c *connection) snapshot() connectionSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := connectionSnapshot{
+		Params: c.params,
+		Nick:   c.nick,
+		Away:   c.away,
+	}
+	for _, channel := range c.channels {
+		s.Channels = append(s.Channels, channelSnapshot{channel.Name, channel.Key})
+	}
+	sort.Slice(s.Channels, func(i, j int) bool {
+		return s.Channels[i].Name < s.Channels[j].Name
+	})
+	return s
+}
+
+// SaveSnapshot writes the state of every connection to path, for Restore to reconnect them later.
+func (v *Varys) SaveSnapshot(path string, _ *struct{}) error {
+	v.mu.RLock()
+	s := snapshot{Version: snapshotVersion}
+	for _, c := range v.uidToConns {
+		s.Connections = append(s.Connections, c.snapshot())
+	}
+	v.mu.RUnlock()
+
+	sort.Slice(s.Connections, func(i, j int) bool {
+		return s.Connections[i].Params.key() < s.Connections[j].Params.key()
+	})
+
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return fmt.Errorf("could not encode snapshot: %w", err)
+	}
+
+	// Write to a temporary file first, so that a crash doesn't leave a partial snapshot
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("could not write snapshot: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not write snapshot: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("could not write snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore reconnects the connections in the snapshot at path, rejoining
+// their channels and restoring their away messages once they have connected.
+//
+// UIDs that are already connected are skipped, and never replaced.
+func (v *Varys) Restore(path string, result *[]ConnectResult) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read snapshot: %w", err)
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("could not decode snapshot: %w", err)
+	}
+	if s.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d, expected %d", s.Version, snapshotVersion)
+	}
+
+	var conns []*connection
+	v.mu.RLock()
+	for _, saved := range s.Connections {
+		if _, ok := v.uidToConns[saved.Params.key()]; ok {
+			continue
+		}
+
+		conns = append(conns, v.restoreConnection(saved))
+	}
+	v.mu.RUnlock()
+
+	// Snapshots of idle connections are superseded by this one.
+	// Connections made since we checked are rejected by connect, rather than replaced.
+	for _, c := range conns {
+		v.forgetIdle(c.params.key())
+	}
+
+	*result = v.connectAll(conns, defaultConnectParallelism)
+	return nil
+}
+
+// restoreConnection makes a connection from a snapshot, which rejoins its channels
+// and restores its away message once it has connected.
+func (v *Varys) restoreConnection(saved connectionSnapshot) *connection {
+	c := newConnection(saved.Params, v.config().RateLimit)
+	if saved.Nick != "" {
+		c.nick = saved.Nick
+	}
+	c.away = saved.Away
+	for _, channel := range saved.Channels {
+		c.channels[strings.ToLower(channel.Name)] = joinedChannel{Name: channel.Name, Key: channel.Key}
+	}
+	return c
+}
@@ -0,0 +1,54 @@
+package varys
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "varys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	v := NewVarys(nil)
+	c := newConnection(ConnectParams{UID: "1234", Nick: "qais~d"}, RateLimit{})
+	c.away = "Idle"
+	c.channels["#b"] = joinedChannel{Name: "#B", Key: "key", Joined: true}
+	c.channels["#a"] = joinedChannel{Name: "#a"}
+	v.uidToConns["1234"] = c
+
+	path := filepath.Join(dir, "snapshot.json")
+	assert.NoError(t, v.SaveSnapshot(path, nil))
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+
+	var s snapshot
+	assert.NoError(t, json.Unmarshal(data, &s))
+	assert.Equal(t, snapshot{
+		Version: snapshotVersion,
+		Connections: []connectionSnapshot{{
+			Params:   ConnectParams{UID: "1234", Nick: "qais~d"},
+			Nick:     "qais~d",
+			Channels: []channelSnapshot{{"#B", "key"}, {"#a", ""}},
+			Away:     "Idle",
+		}},
+	}, s)
+}
+
+func TestRestoreVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "varys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"Version": 2}`), 0600))
+
+	var results []ConnectResult
+	assert.Error(t, NewVarys(nil).Restore(path, &results))
+}
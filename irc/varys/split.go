@@ -0,0 +1,107 @@
+package varys
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLineLength is the maximum length of an IRC line, including the trailing CRLF
+const maxLineLength = 512
+
+// maxHostLength is a conservative guess at the length of our host, as seen by other clients
+const maxHostLength = 63
+
+// splitMessage splits a PRIVMSG or NOTICE into several lines, so that each line
+// still fits once the server relays it with our ":nick!user@host" prefix.
+//
+// Lines are broken on spaces where possible, and never in the middle of a rune.
+// Other commands, and messages that already fit, are returned as is.
+func splitMessage(msg string, nick string, user string) []string {
+	line := strings.TrimRight(msg, "\r\n")
+
+	// COMMAND target :text
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) != 3 || !strings.HasPrefix(fields[2], ":") {
+		return []string{msg}
+	}
+
+	command := strings.ToUpper(fields[0])
+	if command != "PRIVMSG" && command != "NOTICE" {
+		return []string{msg}
+	}
+
+	header := fields[0] + " " + fields[1] + " :"
+	text := fields[2][1:]
+
+	// Keep CTCP framing (such as ACTION) intact on every line
+	var ctcpPrefix, ctcpSuffix string
+	if len(text) > 1 && text[0] == '\x01' && text[len(text)-1] == '\x01' {
+		inner := text[1 : len(text)-1]
+		if i := strings.IndexByte(inner, ' '); i != -1 {
+			ctcpPrefix = "\x01" + inner[:i+1]
+			ctcpSuffix = "\x01"
+			text = inner[i+1:]
+		}
+	}
+
+	// ":nick!~user@host " is prepended by the server, and "\r\n" appended
+	prefixLength := len(":!~@ ") + len(nick) + len(user) + maxHostLength
+	budget := maxLineLength - len("\r\n") - prefixLength - len(header) - len(ctcpPrefix) - len(ctcpSuffix)
+	if len(text) <= budget || budget <= 0 {
+		return []string{msg}
+	}
+
+	var lines []string
+	for _, chunk := range splitText(text, budget) {
+		lines = append(lines, header+ctcpPrefix+chunk+ctcpSuffix)
+	}
+	return lines
+}
+
+// splitText splits text into chunks of at most size bytes.
+func splitText(text string, size int) []string {
+	var chunks []string
+	for len(text) > size {
+		// Don't cut a rune in half
+		end := size
+		for end > 0 && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		if end == 0 {
+			// A single rune is larger than size, so there is nothing sensible we can do
+			_, end = utf8.DecodeRuneInString(text)
+		}
+
+		// Prefer to break on a space, dropping the space itself
+		next := end
+		if i := strings.LastIndexByte(text[:end], ' '); i > 0 {
+			end, next = i, i+1
+		}
+
+		chunks = append(chunks, text[:end])
+		text = text[next:]
+	}
+	return append(chunks, text)
+}
+
+// sendSplit sends msg, split across several lines if needed,
+// returning how many lines were sent and their total length.
+//
+// Each line is sent with tags, if the server supports message tags.
+func (c *connection) sendSplit(msg string, tags map[string]string) (lines int, bytes int) {
+	conn := c.irc()
+
+	prefix := ""
+	if hasCap(conn, "message-tags") {
+		prefix = formatTags(tags)
+	}
+
+	cs := c.encoding()
+	for _, line := range splitMessage(msg, c.currentNick(), c.params.Username) {
+		line = prefix + cs.encode(line)
+		c.send(line)
+		lines++
+		bytes += len(line)
+	}
+	return lines, bytes
+}
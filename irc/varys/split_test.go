@@ -0,0 +1,61 @@
+package varys
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitMessageShort(t *testing.T) {
+	assert.Equal(t, []string{"PRIVMSG #test :hello"}, splitMessage("PRIVMSG #test :hello", "nick", "user"))
+	assert.Equal(t, []string{"JOIN #test"}, splitMessage("JOIN #test", "nick", "user"))
+
+	long := "TOPIC #test :" + strings.Repeat("a", 600)
+	assert.Equal(t, []string{long}, splitMessage(long, "nick", "user"))
+}
+
+func TestSplitMessageWords(t *testing.T) {
+	msg := "PRIVMSG #test :" + strings.Repeat("word ", 200)
+	lines := splitMessage(msg, "nick", "user")
+	assert.True(t, len(lines) > 1)
+
+	var words int
+	for _, line := range lines {
+		assert.True(t, strings.HasPrefix(line, "PRIVMSG #test :"))
+		assert.LessOrEqual(t, len(":nick!~user@ \r\n")+maxHostLength+len(line), maxLineLength)
+
+		text := strings.TrimPrefix(line, "PRIVMSG #test :")
+		for _, word := range strings.Fields(text) {
+			assert.Equal(t, "word", word)
+			words++
+		}
+	}
+	assert.Equal(t, 200, words)
+}
+
+func TestSplitMessageRunes(t *testing.T) {
+	msg := "PRIVMSG #test :" + strings.Repeat("🔴", 300)
+	lines := splitMessage(msg, "nick", "user")
+	assert.True(t, len(lines) > 1)
+
+	var text string
+	for _, line := range lines {
+		chunk := strings.TrimPrefix(line, "PRIVMSG #test :")
+		assert.True(t, utf8.ValidString(chunk))
+		text += chunk
+	}
+	assert.Equal(t, strings.Repeat("🔴", 300), text)
+}
+
+func TestSplitMessageAction(t *testing.T) {
+	msg := "PRIVMSG #test :\x01ACTION " + strings.Repeat("waves ", 150) + "\x01\r\n"
+	lines := splitMessage(msg, "nick", "user")
+	assert.True(t, len(lines) > 1)
+
+	for _, line := range lines {
+		assert.True(t, strings.HasPrefix(line, "PRIVMSG #test :\x01ACTION "))
+		assert.True(t, strings.HasSuffix(line, "\x01"))
+	}
+}
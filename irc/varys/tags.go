@@ -0,0 +1,73 @@
+package varys
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// tagCaps are the IRCv3 capabilities for message tags, requested when available
+var tagCaps = []string{"message-tags", "server-time"}
+
+// serverTimeFormat is the format of the server-time "time" tag
+const serverTimeFormat = "2006-01-02T15:04:05.000Z"
+
+var tagValueEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\:`,
+	" ", `\s`,
+	"\r", `\r`,
+	"\n", `\n`,
+)
+
+// formatTags formats tags as a message tag prefix, such as "@msgid=abc;+draft/reply=def ".
+// Tags are sorted by key, and tags without a value are sent as just their key.
+func formatTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteByte('@')
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(';')
+		}
+		b.WriteString(key)
+		if value := tags[key]; value != "" {
+			b.WriteByte('=')
+			b.WriteString(tagValueEscaper.Replace(value))
+		}
+	}
+	b.WriteByte(' ')
+	return b.String()
+}
+
+// eventTime returns when the server says e happened, or now if it didn't say.
+func eventTime(e *irc.Event) time.Time {
+	if t, err := time.Parse(serverTimeFormat, e.Tags["time"]); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+// hasCap returns true if the server acknowledged the capability on conn.
+func hasCap(conn *irc.Connection, capability string) bool {
+	if conn == nil {
+		return false
+	}
+	for _, c := range conn.AcknowledgedCaps {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package varys
+
+import (
+	"testing"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatTags(t *testing.T) {
+	assert.Equal(t, "", formatTags(nil))
+	assert.Equal(t, "@msgid=abc ", formatTags(map[string]string{"msgid": "abc"}))
+	assert.Equal(t, "@+draft/reply=abc;+typing ", formatTags(map[string]string{
+		"+typing":      "",
+		"+draft/reply": "abc",
+	}))
+	assert.Equal(t, `@label=a\sb\:c\\d\r\n `, formatTags(map[string]string{"label": "a b;c\\d\r\n"}))
+}
+
+func TestEventTime(t *testing.T) {
+	e := &irc.Event{Tags: map[string]string{"time": "2011-10-19T16:40:51.620Z"}}
+	assert.Equal(t, time.Date(2011, 10, 19, 16, 40, 51, 620*int(time.Millisecond), time.UTC), eventTime(e))
+
+	before := time.Now()
+	assert.False(t, eventTime(&irc.Event{}).Before(before))
+}
@@ -0,0 +1,42 @@
+package varys
+
+import (
+	"sync"
+	"time"
+)
+
+// connectThrottle spaces out new connections to the server.
+//
+// It is shared by every UID, because networks limit how fast a single host may connect.
+type connectThrottle struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// reserve returns when the next connection may be made, and books it.
+func (t *connectThrottle) reserve(now time.Time, delay time.Duration) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	at := t.next
+	if at.Before(now) {
+		at = now
+	}
+	t.next = at.Add(delay)
+	return at
+}
+
+// wait waits for our turn to connect. It returns false if done is closed first.
+func (t *connectThrottle) wait(delay time.Duration, done <-chan struct{}) bool {
+	if delay <= 0 {
+		return true
+	}
+
+	at := t.reserve(time.Now(), delay)
+	select {
+	case <-time.After(time.Until(at)):
+		return true
+	case <-done:
+		return false
+	}
+}
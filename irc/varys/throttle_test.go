@@ -0,0 +1,23 @@
+package varys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectThrottleReserve(t *testing.T) {
+	var throttle connectThrottle
+	now := time.Now()
+	delay := 2 * time.Second
+
+	// Concurrent connections are spaced out
+	assert.Equal(t, now, throttle.reserve(now, delay))
+	assert.Equal(t, now.Add(delay), throttle.reserve(now, delay))
+	assert.Equal(t, now.Add(2*delay), throttle.reserve(now.Add(time.Second), delay))
+
+	// Once the throttle has caught up, connections are made straight away
+	later := now.Add(time.Minute)
+	assert.Equal(t, later, throttle.reserve(later, delay))
+}
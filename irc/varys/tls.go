@@ -0,0 +1,108 @@
+package varys
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ClientCertificate is a TLS client certificate and its private key.
+//
+// Provide either paths to PEM encoded files, or the PEM data itself.
+type ClientCertificate struct {
+	CertFile string
+	KeyFile  string
+
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+func (c ClientCertificate) empty() bool {
+	return c.CertFile == "" && c.KeyFile == "" && len(c.CertPEM) == 0 && len(c.KeyPEM) == 0
+}
+
+func (c ClientCertificate) load() (tls.Certificate, error) {
+	if len(c.CertPEM) > 0 || len(c.KeyPEM) > 0 {
+		return tls.X509KeyPair(c.CertPEM, c.KeyPEM)
+	}
+	return tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+}
+
+// tlsConfig returns the tls.Config to use for a connection, or nil if the defaults are fine.
+func (v *Varys) tlsConfig(params ConnectParams, server string) (*tls.Config, error) {
+	setup := v.config()
+
+	cert := params.ClientCertificate
+	if cert.empty() {
+		cert = setup.ClientCertificate
+	}
+
+	if !setup.InsecureSkipVerify && cert.empty() && setup.TLSMinVersion == 0 &&
+		len(setup.TLSCipherSuites) == 0 && setup.TLSServerName == "" && len(setup.TLSPins) == 0 {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		InsecureSkipVerify: setup.InsecureSkipVerify,
+		MinVersion:         setup.TLSMinVersion,
+		CipherSuites:       setup.TLSCipherSuites,
+	}
+
+	if setup.TLSServerName != "" {
+		config.ServerName = setup.TLSServerName
+	} else if host, _, err := net.SplitHostPort(server); err == nil {
+		config.ServerName = host
+	}
+
+	if len(setup.TLSPins) > 0 {
+		// The pins are checked instead of the certificate chain
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = verifyPins(setup.TLSPins)
+	}
+
+	if !cert.empty() {
+		c, err := cert.load()
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{c}
+	}
+
+	return config, nil
+}
+
+// normalisePin lowercases a fingerprint, and removes any colons.
+func normalisePin(pin string) string {
+	return strings.ToLower(strings.ReplaceAll(pin, ":", ""))
+}
+
+func validPin(pin string) bool {
+	b, err := hex.DecodeString(pin)
+	return err == nil && len(b) == sha256.Size
+}
+
+// verifyPins returns a tls.Config.VerifyPeerCertificate that accepts
+// the server's certificate if its SHA-256 fingerprint is one of pins.
+func verifyPins(pins []string) func([][]byte, [][]*x509.Certificate) error {
+	accepted := make(map[string]bool, len(pins))
+	for _, pin := range pins {
+		accepted[normalisePin(pin)] = true
+	}
+
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("%w: the server didn't send a certificate", ErrTLSPinMismatch)
+		}
+
+		sum := sha256.Sum256(rawCerts[0])
+		fingerprint := hex.EncodeToString(sum[:])
+		if !accepted[fingerprint] {
+			return fmt.Errorf("%w: got %s", ErrTLSPinMismatch, fingerprint)
+		}
+		return nil
+	}
+}
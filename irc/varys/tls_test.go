@@ -0,0 +1,67 @@
+package varys
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSConfig(t *testing.T) {
+	v := NewVarys(nil)
+
+	config, err := v.tlsConfig(ConnectParams{}, "irc.example.com:6697")
+	assert.NoError(t, err)
+	assert.Nil(t, config, "the defaults should be left alone")
+
+	assert.NoError(t, v.Setup(SetupParams{
+		InsecureSkipVerify: true,
+		TLSMinVersion:      tls.VersionTLS13,
+		TLSCipherSuites:    []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+	}, nil))
+
+	config, err = v.tlsConfig(ConnectParams{}, "irc.example.com:6697")
+	assert.NoError(t, err)
+	assert.Equal(t, "irc.example.com", config.ServerName)
+	assert.True(t, config.InsecureSkipVerify)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, config.CipherSuites)
+
+	assert.NoError(t, v.Setup(SetupParams{TLSServerName: "irc.example.net"}, nil))
+	config, err = v.tlsConfig(ConnectParams{}, "lb.example.com:6697")
+	assert.NoError(t, err)
+	assert.Equal(t, "irc.example.net", config.ServerName)
+}
+
+func TestPlaintextServer(t *testing.T) {
+	assert.Equal(t, "irc.example.com:6667", plaintextServer("irc.example.com:6697", ":6667"))
+	assert.Equal(t, "plain.example.com:6667", plaintextServer("irc.example.com:6697", "plain.example.com:6667"))
+}
+
+func TestVerifyPins(t *testing.T) {
+	cert := []byte("not really a certificate")
+	sum := sha256.Sum256(cert)
+	pin := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	assert.True(t, validPin(normalisePin(pin)))
+	assert.False(t, validPin(normalisePin("ab:cd")))
+
+	verify := verifyPins([]string{pin})
+	assert.NoError(t, verify([][]byte{cert}, nil))
+
+	err := verify([][]byte{[]byte("another certificate")}, nil)
+	assert.ErrorIs(t, err, ErrTLSPinMismatch)
+	assert.ErrorIs(t, err, ErrTLSHandshake)
+
+	v := NewVarys(nil)
+	assert.Error(t, v.Setup(SetupParams{TLSPins: []string{"not a pin"}}, nil))
+	assert.NoError(t, v.Setup(SetupParams{TLSPins: []string{pin}}, nil))
+
+	config, err := v.tlsConfig(ConnectParams{}, "irc.example.com:6697")
+	assert.NoError(t, err)
+	assert.True(t, config.InsecureSkipVerify, "the pins replace the usual verification")
+	assert.NotNil(t, config.VerifyPeerCertificate)
+}
@@ -0,0 +1,185 @@
+package varys
+
+import (
+	"errors"
+	"strings"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// setTopic caches the topic of a channel, if we are in it.
+// Topics of channels we aren't in can change without us being told.
+func (c *connection) setTopic(channel string, topic string) {
+	name := strings.ToLower(channel)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ch, ok := c.channels[name]; ok && ch.Joined {
+		c.topics[name] = topic
+	}
+}
+
+// cachedTopic returns the cached topic of a channel.
+func (c *connection) cachedTopic(channel string) (topic string, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	topic, ok = c.topics[strings.ToLower(channel)]
+	return
+}
+
+// trackTopics caches the topics of the channels conn is in, and
+// completes pending topic queries.
+func (c *connection) trackTopics(conn *irc.Connection) {
+	forget := func(channel string) {
+		c.mu.Lock()
+		delete(c.topics, strings.ToLower(channel))
+		c.mu.Unlock()
+	}
+
+	reply := func(channel string, topic string) {
+		c.setTopic(channel, topic)
+
+		key := queryKey("topic", channel)
+		c.queries.update(key, func(value interface{}) {
+			*value.(*string) = topic
+		})
+		c.queries.finish(key, nil)
+	}
+
+	// RPL_NOTOPIC <me> <channel> :No topic is set
+	conn.AddCallback("331", func(e *irc.Event) {
+		if len(e.Arguments) >= 2 {
+			reply(e.Arguments[1], "")
+		}
+	})
+
+	// RPL_TOPIC <me> <channel> :<topic>
+	conn.AddCallback("332", func(e *irc.Event) {
+		if len(e.Arguments) >= 3 {
+			reply(e.Arguments[1], e.Arguments[2])
+		}
+	})
+
+	conn.AddCallback("TOPIC", func(e *irc.Event) {
+		if len(e.Arguments) < 2 {
+			return
+		}
+		c.setTopic(e.Arguments[0], e.Arguments[1])
+		if c.isMe(e.Nick) {
+			c.queries.finish(queryKey("settopic", e.Arguments[0]), nil)
+		}
+	})
+
+	// ERR_NOSUCHCHANNEL, ERR_NOTONCHANNEL and ERR_CHANOPRIVSNEEDED <me> <channel> :<reason>
+	fail := func(e *irc.Event, err error) {
+		if len(e.Arguments) < 2 {
+			return
+		}
+		c.queries.finish(queryKey("topic", e.Arguments[1]), err)
+		c.queries.finish(queryKey("settopic", e.Arguments[1]), err)
+	}
+	conn.AddCallback("403", func(e *irc.Event) {
+		fail(e, errors.New("no such channel: "+e.Message()))
+	})
+	conn.AddCallback("442", func(e *irc.Event) {
+		fail(e, errors.New("not on channel: "+e.Message()))
+	})
+	conn.AddCallback("482", func(e *irc.Event) {
+		fail(e, errors.New("cannot change the topic without channel operator privileges: "+e.Message()))
+	})
+
+	// We stop seeing topic changes for channels we leave
+	conn.AddCallback("PART", func(e *irc.Event) {
+		if len(e.Arguments) > 0 && c.isMe(e.Nick) {
+			forget(e.Arguments[0])
+		}
+	})
+	conn.AddCallback("KICK", func(e *irc.Event) {
+		if len(e.Arguments) >= 2 && c.isMe(e.Arguments[1]) {
+			forget(e.Arguments[0])
+		}
+	})
+
+	// Topics may have changed while we were disconnected
+	conn.AddCallback("001", func(e *irc.Event) {
+		c.mu.Lock()
+		c.topics = make(map[string]string)
+		c.mu.Unlock()
+	})
+}
+
+type TopicParams struct {
+	UID     string
+	Channel string
+}
+
+// GetTopic returns the topic of a channel. If the UID is blank, any connection is used.
+//
+// Topics of channels the connection is in are cached; other channels are asked for.
+func (v *Varys) GetTopic(params TopicParams, result *string) error {
+	if err := validTarget(params.Channel); err != nil {
+		return err
+	}
+
+	c, err := v.queryConn(params.UID)
+	if err != nil {
+		return err
+	}
+
+	if topic, ok := c.cachedTopic(params.Channel); ok {
+		*result = topic
+		return nil
+	}
+
+	key := queryKey("topic", params.Channel)
+	wait := c.queries.start(key, new(string), func() {
+		c.send("TOPIC " + params.Channel)
+	})
+
+	value, err := c.queries.wait(key, wait, queryTimeout)
+	if err != nil {
+		return err
+	}
+
+	*result = *value.(*string)
+	return nil
+}
+
+type SetTopicParams struct {
+	UID     string
+	Channel string
+	Topic   string
+}
+
+// SetTopic changes the topic of a channel, waiting for the server to accept it.
+func (v *Varys) SetTopic(params SetTopicParams, _ *struct{}) error {
+	if err := validTarget(params.Channel); err != nil {
+		return err
+	}
+
+	c, err := v.queryConn(params.UID)
+	if err != nil {
+		return err
+	}
+
+	// Servers silently truncate long topics, which would stop us recognising them later
+	topic := lineQuote.Replace(params.Topic)
+	if max, ok := c.isupportInt("TOPICLEN"); ok {
+		topic = truncate(topic, max)
+	}
+
+	// Servers don't announce topic changes that don't change anything
+	if cached, ok := c.cachedTopic(params.Channel); ok && cached == topic {
+		return nil
+	}
+
+	key := queryKey("settopic", params.Channel)
+	wait := c.queries.start(key, nil, func() {
+		c.send("TOPIC " + params.Channel + " :" + topic)
+	})
+
+	_, err = c.queries.wait(key, wait, queryTimeout)
+	return err
+}
@@ -5,69 +5,303 @@
 package varys
 
 import (
-	"crypto/tls"
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	irc "github.com/qaisjp/go-ircevent"
+	"github.com/sirupsen/logrus"
 )
 
 type Varys struct {
+	// configMu guards connConfig, which can be changed by Setup while connections are using it
+	configMu   sync.RWMutex
 	connConfig SetupParams
-	uidToConns map[string]*irc.Connection
+	// defaultCharset is the SetupParams.Charset, or nil for UTF-8
+	defaultCharset *charset
+
+	mu         sync.RWMutex
+	uidToConns map[string]*connection
+	// idle are the connections quit for being idle, by UID, which are reconnected on demand
+	idle map[string]*idleConnection
+
+	throttle connectThrottle
+
+	log *logrus.Logger
+}
+
+// NewVarys returns a Varys that logs to logger, or to logrus's standard logger if logger is nil.
+func NewVarys(logger *logrus.Logger) *Varys {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return &Varys{
+		log:        logger,
+		uidToConns: make(map[string]*connection),
+		idle:       make(map[string]*idleConnection),
+	}
 }
 
-func NewVarys() *Varys {
-	return &Varys{uidToConns: make(map[string]*irc.Connection)}
+// config returns the SetupParams that new connections use.
+func (v *Varys) config() SetupParams {
+	v.configMu.RLock()
+	defer v.configMu.RUnlock()
+	return v.connConfig
 }
 
-func (v *Varys) connCall(uid string, fn func(*irc.Connection)) {
+// charset returns the charset from SetupParams.Charset, or nil for UTF-8.
+func (v *Varys) charset() *charset {
+	v.configMu.RLock()
+	defer v.configMu.RUnlock()
+	return v.defaultCharset
+}
+
+// connCall calls fn for the given uid, or for all connections if uid is blank.
+func (v *Varys) connCall(uid string, fn func(*connection)) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
 	if uid == "" {
-		for _, conn := range v.uidToConns {
-			fn(conn)
+		for _, c := range v.uidToConns {
+			fn(c)
 		}
 		return
 	}
 
-	if conn, ok := v.uidToConns[uid]; ok {
-		fn(conn)
+	if c, ok := v.uidToConns[uid]; ok {
+		fn(c)
 	}
 }
 
 type Client interface {
 	Setup(params SetupParams) error
 	GetUIDToNicks() (map[string]string, error)
-	Connect(params ConnectParams) error // Does not yet support netClient
+	Connect(params ConnectParams) error
+	// ConnectBatch connects many UIDs concurrently, returning a result for each
+	ConnectBatch(connections []ConnectParams, parallelism int) ([]ConnectResult, error)
+	// SaveSnapshot saves the state of every connection to a file
+	SaveSnapshot(path string) error
+	// Restore reconnects the connections saved by SaveSnapshot, returning a result for each
+	Restore(path string) ([]ConnectResult, error)
 	QuitIfConnected(uid string, quitMsg string) error
+	// ForceQuit is like QuitIfConnected, but drops messages that haven't been sent yet
+	ForceQuit(uid string, quitMsg string) error
+	// QuitAll quits every connection, waiting for them to close
+	QuitAll(quitMsg string) error
 	Nick(uid string, nick string) error
+	// Join joins a channel, which is rejoined after reconnecting.
+	// A *JoinError is returned if the server refuses to let us in.
+	Join(uid string, channel string, key string) error
+	// Part leaves a channel
+	Part(uid string, channel string, reason string) error
+	// GetChannels returns the channels the connection is currently in
+	GetChannels(uid string) ([]string, error)
+	// GetChannelModes gets the modes of a channel. A blank uid uses any connection.
+	GetChannelModes(uid string, channel string) (map[string]string, error)
 
 	// SendRaw supports a blank uid to send to all connections.
-	SendRaw(uid string, params InterpolationParams, messages ...string) error
+	// Long PRIVMSGs and NOTICEs are split across several lines.
+	SendRaw(uid string, params InterpolationParams, messages ...string) (SendRawResult, error)
+	// SendTagged is like SendRaw, but attaches IRCv3 message tags to each message
+	SendTagged(uid string, tags map[string]string, messages ...string) (SendRawResult, error)
+	// SendMulti sends the same PRIVMSG to each target, returning a result for each
+	SendMulti(uid string, targets []string, text string) ([]SendResult, error)
+	// SendLines sends lines as one multi-line message, or a message per line if the server doesn't support them
+	SendLines(uid string, target string, lines []string) error
+	// SendMessage sends a PRIVMSG to target
+	SendMessage(uid string, target string, text string) error
+	// SendNotice sends a NOTICE to target
+	SendNotice(uid string, target string, text string) error
+	// SendAction sends a CTCP ACTION (/me) to target
+	SendAction(uid string, target string, text string) error
+	// GetQueueDepth returns how many messages are waiting to be sent because of rate limiting
+	GetQueueDepth(uid string) (int, error)
+	// FlushQueue sends queued messages immediately, or drops them, returning how many were flushed
+	FlushQueue(uid string, drop bool) (int, error)
+	// SetDebug turns debug output on or off. A blank uid sets it for all connections.
+	SetDebug(uid string, enabled bool) error
+	// Reauth authenticates a connection again using SASL, without reconnecting
+	Reauth(uid string) error
+	// SetAway sets the connection's away message. A blank message marks it as back.
+	SetAway(uid string, message string) error
+	// Whois looks up a user. A blank uid uses any connection.
+	Whois(uid string, target string) (WhoisResult, error)
+	// Kick kicks a user from a channel, failing if we aren't a channel operator
+	Kick(uid string, channel string, target string, reason string) error
+	// SetBan bans masks from a channel, failing if we aren't a channel operator
+	SetBan(uid string, channel string, masks ...string) error
+	// RemoveBan unbans masks from a channel, failing if we aren't a channel operator
+	RemoveBan(uid string, channel string, masks ...string) error
+	// ServiceQuery sends command to a service, such as ChanServ, and returns its replies
+	ServiceQuery(uid string, service string, command string) ([]string, error)
+	// Who lists the users matching mask, with their accounts if the server supports WHOX
+	Who(uid string, mask string) ([]WhoRow, error)
+	// Names lists the members of a channel, with their status prefixes
+	Names(uid string, channel string) ([]ChannelMember, error)
+	// GetTopic gets the topic of a channel. A blank uid uses any connection.
+	GetTopic(uid string, channel string) (string, error)
+	// SetTopic sets the topic of a channel
+	SetTopic(uid string, channel string, topic string) error
+	// Monitor watches nicks, sending events when they come online or go offline
+	Monitor(uid string, nicks ...string) error
+	// Unmonitor stops watching nicks
+	Unmonitor(uid string, nicks ...string) error
+	// TouchActivity marks a connection as active, reconnecting it if it was quit for being idle
+	TouchActivity(uid string) error
+	// GetISupport returns the features the server advertised in RPL_ISUPPORT
+	GetISupport(uid string) (map[string]string, error)
+	// GetCaps returns the IRCv3 capabilities enabled on a connection, with their advertised values
+	GetCaps(uid string) (map[string]string, error)
 	// GetNick gets the current connection's nick
 	GetNick(uid string) (string, error)
 	// Connected returns the status of the current connection
 	Connected(uid string) (bool, error)
+	// ListConnections returns the state of every connection
+	ListConnections() ([]ConnectionInfo, error)
+	// ResetReconnectCounts resets the reconnection counts in ListConnections. A blank uid resets every connection.
+	ResetReconnectCounts(uid string) error
+	// Health reports liveness, and readiness based on the primary connection (or any, if blank)
+	Health(primary string) (HealthResult, error)
+	// PollEvents drains buffered events. A blank uid drains events from all connections.
+	PollEvents(uid string) ([]Event, error)
 }
 
 type SetupParams struct {
 	UseTLS             bool // Whether we should use TLS
 	InsecureSkipVerify bool // Controls tls.Config.InsecureSkipVerify, if using TLS
 
+	// TLSMinVersion is the minimum TLS version to accept, such as tls.VersionTLS13.
+	// Defaults to the crypto/tls default.
+	TLSMinVersion uint16
+	// TLSCipherSuites restricts the cipher suites used for TLS 1.2 and earlier.
+	// Defaults to the crypto/tls default.
+	TLSCipherSuites []uint16
+	// TLSServerName, if set, is the name the server's certificate is verified against,
+	// instead of the host we dial. This is useful behind load balancers.
+	TLSServerName string
+	// TLSPins, if set, are the SHA-256 fingerprints of the certificates we accept from the server,
+	// as hex with or without colons. They replace the usual verification, so self-signed certificates can be used.
+	TLSPins []string
+
 	Server         string
+	Servers        []string // Servers, if provided, is a pool of servers used instead of Server
 	ServerPassword string
 	WebIRCPassword string
+
+	// ClientCertificate is presented to the server when using TLS,
+	// unless a connection provides its own.
+	ClientCertificate ClientCertificate
+
+	// RateLimit limits how quickly each connection sends messages.
+	// The zero value does not limit connections at all.
+	RateLimit RateLimit
+
+	// NickSuffix is the suffix the client appends to puppet nicks, such as "~d".
+	// Nick collision suffixes are inserted before it.
+	NickSuffix string
+	// MaxNickLength is the maximum length of a nick. Defaults to ircnick.MAXLENGTH.
+	MaxNickLength int
+
+	// NickCollisionSuffix is appended to a nick that is already in use. Defaults to "_".
+	NickCollisionSuffix string
+	// NickCollisionHash uses a short hash of the UID as the collision suffix, instead of NickCollisionSuffix.
+	// The hash is the same every time, so nicks stay the same after reconnecting. See HashedNick.
+	NickCollisionHash bool
+	// NickCollisionAttempts is how many alternative nicks we try before giving up. Defaults to 3.
+	NickCollisionAttempts int
+
+	// NickServPassword, if set, is used to identify with NickServ once connected.
+	NickServPassword string
+	// NickServFormat is the command used to identify, with ${NICK} and ${PASSWORD} interpolated.
+	// ${NICK} is the nick we asked for, even if the server gave us another.
+	// Defaults to "PRIVMSG NickServ :IDENTIFY ${NICK} ${PASSWORD}".
+	NickServFormat string
+
+	// PingFreq is how often we ping the server. Defaults to 4 minutes.
+	PingFreq time.Duration
+	// PingTimeout is how long after a missed ping we consider the connection dead,
+	// and reconnect. Defaults to 1 minute.
+	PingTimeout time.Duration
+
+	// CTCPVersion is our reply to CTCP VERSION. Defaults to identifying the bridge.
+	CTCPVersion string
+
+	// RealNameTemplate, if set, is used to make every connection's realname, such as "${NICK} via Discord".
+	// ${UID}, ${NICK} and ${NAME} are replaced with the UID, nick and display name.
+	RealNameTemplate string
+	// RealNameLength, if set, is the longest realname made from RealNameTemplate, in bytes.
+	// Otherwise the server's NAMELEN is used, once a connection has learnt it.
+	RealNameLength int
+
+	// KickRejoin controls how channels we are kicked from are rejoined
+	KickRejoin RejoinPolicy
+
+	// Invites controls whether we join channels we are invited to. By default, invites are ignored.
+	Invites InvitePolicy
+
+	// QuitMessage is used when quitting without a message. ${NICK} is replaced with the connection's nick.
+	QuitMessage string
+
+	// ConnectDelay is the minimum time between opening any two connections,
+	// to stay within the network's connection flood limits. Defaults to no delay.
+	ConnectDelay time.Duration
+
+	// IdleTimeout, if set, quits connections that haven't sent anything for this long.
+	// They are reconnected the next time they send something, or TouchActivity is called.
+	IdleTimeout time.Duration
+	// IdleQuitMessage is used when quitting idle connections. ${NICK} is replaced with the connection's nick.
+	IdleQuitMessage string
+
+	// RequestCaps, if not nil, replaces the IRCv3 capabilities we request, such as "message-tags".
+	// sasl is requested when SASL is configured, and can't be requested here.
+	// Capabilities the server doesn't support are left disabled, and GetCaps reports those enabled.
+	// Features that need a capability that wasn't enabled continue without it.
+	RequestCaps []string
+
+	// Charset is the charset used on networks that aren't UTF-8 clean, such as "ISO-8859-1" or "Windows-1252".
+	// Messages we send are converted to it, and messages we receive are converted back to UTF-8.
+	// Defaults to UTF-8, and is ignored on servers that advertise UTF8ONLY.
+	Charset string
+
+	// DedupWindow, if set, drops a PRIVMSG or NOTICE sent with SendRaw when it is identical to
+	// the previous one, sent within this long. This guards against relaying the same line twice.
+	DedupWindow time.Duration
+	// DedupAcrossTargets compares messages with the previous message to any target,
+	// rather than the previous message to the same target.
+	DedupAcrossTargets bool
 }
 
 func (v *Varys) Setup(params SetupParams, _ *struct{}) error {
+	charset, err := lookupCharset(params.Charset)
+	if err != nil {
+		return err
+	}
+	for _, pin := range params.TLSPins {
+		if !validPin(normalisePin(pin)) {
+			return fmt.Errorf("invalid tls pin %q: must be a hex SHA-256 fingerprint", pin)
+		}
+	}
+
+	v.configMu.Lock()
 	v.connConfig = params
+	v.defaultCharset = charset
+	v.configMu.Unlock()
 	return nil
 }
 
 func (v *Varys) GetUIDToNicks(_ struct{}, result *map[string]string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
 	conns := v.uidToConns
 	m := make(map[string]string, len(conns))
-	for uid, conn := range conns {
-		m[uid] = conn.GetNick()
+	for uid, c := range conns {
+		m[uid] = c.currentNick()
 	}
 	*result = m
 	return nil
@@ -76,104 +310,492 @@ func (v *Varys) GetUIDToNicks(_ struct{}, result *map[string]string) error {
 type ConnectParams struct {
 	UID string
 
+	// Instance distinguishes several connections for the same UID, such as one per network.
+	// Connections with an Instance are addressed as "UID/Instance" by the other RPCs.
+	Instance string
+
+	// Server, if provided, overrides the servers in SetupParams
+	Server string
+
 	Nick     string
 	Username string
 	RealName string
 
+	// DisplayName is used for ${NAME} in SetupParams.RealNameTemplate. Defaults to RealName.
+	DisplayName string
+
+	// WebIRC spoofs the connection's hostname and IP address
+	WebIRC WebIRC
+	// WebIRCSuffix is the WebIRC command after the password, used if WebIRC is empty.
+	// Deprecated: use WebIRC, which is validated.
 	WebIRCSuffix string
 
-	// TODO(qaisjp): does not support net/rpc!!!!
-	Callbacks map[string]func(*irc.Event)
+	// SASL PLAIN credentials. Leave SASLUsername blank to skip SASL.
+	// If SASLExternal is also used, PLAIN is used when the server doesn't support EXTERNAL.
+	SASLUsername string
+	SASLPassword string
+
+	// ServerPassword overrides SetupParams.ServerPassword, for networks that
+	// authenticate each user with PASS, such as "account:password".
+	ServerPassword string
+
+	// ClientCertificate overrides SetupParams.ClientCertificate,
+	// so that each puppet can present a different CertFP.
+	ClientCertificate ClientCertificate
+
+	// SASLExternal authenticates using the client certificate (AUTHENTICATE EXTERNAL)
+	SASLExternal bool
+
+	// Callbacks are only supported by the in-memory client.
+	// Use Events to receive events over net/rpc.
+	Callbacks map[string]func(*irc.Event) `json:"-"`
+
+	// Events are the event codes to buffer for PollEvents, e.g. "PRIVMSG" or "*" for everything.
+	Events []string
+
+	// Timeout is how long we wait to connect and be welcomed by the server. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	// PlaintextFallback, if set, is where to connect without TLS if the server doesn't speak TLS,
+	// such as "irc.example.com:6667", or ":6667" for the same host. Only used with SetupParams.UseTLS.
+	//
+	// We never fall back if the server's certificate fails verification or doesn't match a pin,
+	// as that is what someone intercepting the connection would cause.
+	// STARTTLS isn't tried, as go-ircevent can't upgrade a connection once it is open.
+	PlaintextFallback string
+}
+
+// key is how the connection is addressed by RPCs
+func (p ConnectParams) key() string {
+	if p.Instance == "" {
+		return p.UID
+	}
+	return p.UID + "/" + p.Instance
 }
 
+// Connect connects a UID, returning a *ConnectError if the connection can't be established,
+// or ErrAlreadyConnected if the UID is already connected.
 func (v *Varys) Connect(params ConnectParams, _ *struct{}) error {
-	conn := irc.IRC(params.Nick, params.Username)
-	// conn.Debug = true
-	conn.RealName = params.RealName
+	v.forgetIdle(params.key())
+	return v.connect(newConnection(params, v.config().RateLimit))
+}
+
+// connect establishes c, and keeps it connected until it quits.
+func (v *Varys) connect(c *connection) error {
+	// Callbacks can send to c before the server has finished welcoming us, such as
+	// joining channels on 001, so c is available to RPCs straight away.
+	// Until then, messages are held, and sent once we are connected.
+	if err := v.add(c); err != nil {
+		return err
+	}
+
+	server := v.pickServer(c, false)
+	if err := v.dial(c, server); err != nil {
+		v.remove(c)
+		return &ConnectError{UID: c.params.key(), Server: server, Err: err}
+	}
+
+	go v.loop(c)
+	if c.limiter != nil {
+		go c.sendLoop()
+	}
+	if timeout := v.config().IdleTimeout; timeout > 0 {
+		go v.watchIdle(c, timeout)
+	}
+	return nil
+}
+
+// add makes c available to RPCs under its UID, unless the UID is already connected.
+func (v *Varys) add(c *connection) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key := c.params.key()
+	if _, ok := v.uidToConns[key]; ok {
+		return fmt.Errorf("could not connect %s: %w", key, ErrAlreadyConnected)
+	}
+	v.uidToConns[key] = c
+	return nil
+}
+
+// remove makes c unavailable to RPCs, unless it has already been replaced.
+func (v *Varys) remove(c *connection) {
+	v.mu.Lock()
+	if v.uidToConns[c.params.key()] == c {
+		delete(v.uidToConns, c.params.key())
+	}
+	v.mu.Unlock()
+}
+
+// dial establishes a new IRC connection for c to server, using its ConnectParams.
+// If the TLS handshake fails, it falls back to plaintext when the ConnectParams allow it.
+//
+// This is used both for the initial connection and for reconnecting.
+func (v *Varys) dial(c *connection, server string) error {
+	useTLS := v.config().UseTLS
+	err := v.dialTransport(c, server, useTLS)
+	if err == nil || !useTLS || c.params.PlaintextFallback == "" || !errors.Is(err, ErrTLSUnsupported) {
+		return err
+	}
+
+	plain := plaintextServer(server, c.params.PlaintextFallback)
+	plainErr := v.dialTransport(c, plain, false)
+	if plainErr == nil {
+		return nil
+	}
+	return &TransportError{Attempts: []TransportAttempt{
+		{Transport: TransportTLS, Server: server, Err: err},
+		{Transport: TransportPlaintext, Server: plain, Err: plainErr},
+	}}
+}
+
+// Transports a connection can use, as in ConnectionInfo.Transport
+const (
+	TransportTLS       = "tls"
+	TransportPlaintext = "plaintext"
+)
+
+// plaintextServer returns the address to fall back to for server.
+// A fallback of just a port, such as ":6667", uses the same host as server.
+func plaintextServer(server string, fallback string) string {
+	if !strings.HasPrefix(fallback, ":") {
+		return fallback
+	}
+	if host, _, err := net.SplitHostPort(server); err == nil {
+		return net.JoinHostPort(host, fallback[1:])
+	}
+	return server + fallback
+}
+
+// dialTransport is dial, either with or without TLS.
+func (v *Varys) dialTransport(c *connection, server string, useTLS bool) error {
+	params := c.params
+	config, cs := v.config(), v.charset()
+
+	c.mu.Lock()
+	nick := c.nick
+	c.current = nick
+	c.host = ""
+	c.realHost = ""
+	c.account = ""
+	c.caps = nil
+	c.advertised = make(map[string]string)
+	c.nickAttempts = 0
+	c.charset = cs
+	c.mu.Unlock()
+
+	conn := irc.IRC(nick, params.Username)
+	conn.Log = v.ircLogger(c)
+	conn.Debug = true
+	nameLen, _ := c.isupportInt("NAMELEN")
+	conn.RealName = config.realName(params, nick, nameLen)
+	conn.PingFreq = config.pingFreq()
+	conn.Timeout = params.timeout()
 
 	// TLS things, and the server password
-	conn.Password = v.connConfig.ServerPassword
-	conn.UseTLS = v.connConfig.UseTLS
-	if v.connConfig.InsecureSkipVerify {
-		conn.TLSConfig = &tls.Config{
-			InsecureSkipVerify: true,
-		}
+	conn.Password = config.ServerPassword
+	if params.ServerPassword != "" {
+		conn.Password = params.ServerPassword
 	}
+	conn.UseTLS = useTLS
+	tlsConfig, err := v.tlsConfig(params, server)
+	if err != nil {
+		return fmt.Errorf("error configuring tls: %w", err)
+	}
+	conn.TLSConfig = tlsConfig
+
+	// Request IRCv3 capabilities, where the server supports them
+	conn.RequestCaps = append(conn.RequestCaps, config.requestCaps()...)
 
-	// Set up WebIRC, if a suffix is provided
-	if params.WebIRCSuffix != "" {
-		conn.WebIRC = v.connConfig.WebIRCPassword + " " + params.WebIRCSuffix
+	// Set up WebIRC, if a host or suffix is provided
+	if !params.WebIRC.empty() {
+		webirc, err := params.WebIRC.params()
+		if err != nil {
+			return err
+		}
+		conn.WebIRC = config.WebIRCPassword + " " + webirc
+	} else if params.WebIRCSuffix != "" {
+		conn.WebIRC = config.WebIRCPassword + " " + params.WebIRCSuffix
 	}
 
-	// On kick, rejoin the channel
-	conn.AddCallback("KICK", func(e *irc.Event) {
-		if e.Arguments[1] == conn.GetNick() {
-			conn.Join(e.Arguments[0])
+	// Remember our channels, so that we can rejoin them after
+	// being kicked, or after reconnecting
+	c.trackNick(conn)
+	c.trackHost(conn)
+	c.trackAccount(conn)
+	c.trackCaps(conn)
+	c.trackISupport(conn)
+	c.trackChannels(conn, config.KickRejoin)
+	c.handleJoinErrors(conn)
+	c.handleInvites(conn, config.Invites, config.KickRejoin)
+	c.trackModes(conn)
+	c.measureLatency(conn)
+	c.watchThrottling(conn)
+	answerPings(conn)
+	c.trackMonitor(conn)
+	c.restoreAway(conn)
+	c.handleWhois(conn)
+	c.handleNames(conn)
+	c.handleWho(conn)
+	c.handleServiceReplies(conn)
+	c.handleModeration(conn)
+	c.trackTopics(conn)
+
+	reg := watchRegistration(conn, config.nickServCommand(nick))
+	activity := watchActivity(conn)
+	v.handleNickCollisions(c, conn, reg)
+	v.handleCTCP(c, conn)
+
+	// Authenticate using SASL, if requested
+	if params.SASLExternal {
+		if !conn.UseTLS || conn.TLSConfig == nil || len(conn.TLSConfig.Certificates) == 0 {
+			return errors.New("sasl external requires tls and a client certificate")
 		}
-	})
+	}
+	if params.SASLExternal || params.SASLUsername != "" {
+		conn.UseSASL = true
+		conn.SASLLogin = params.SASLUsername
+		conn.SASLPassword = params.SASLPassword
+		watchSASL(conn, reg)
+		chooseSASLMech(conn, reg, params.SASLExternal, params.SASLUsername != "")
+	}
 
 	for eventcode, callback := range params.Callbacks {
-		conn.AddCallback(eventcode, callback)
+		callback := callback
+		conn.AddCallback(eventcode, func(e *irc.Event) {
+			// Echoes are only delivered as events, so callbacks don't mistake them for messages to us
+			if !c.isEcho(conn, e) {
+				callback(c.encoding().decodeEvent(e))
+			}
+		})
 	}
 
-	err := conn.Connect(v.connConfig.Server)
-	if err != nil {
-		return fmt.Errorf("error opening irc connection: %w", err)
+	for _, eventcode := range params.Events {
+		conn.AddCallback(eventcode, func(e *irc.Event) {
+			event := newEvent(params.key(), c.encoding().decodeEvent(e))
+			event.Echo = c.isEcho(conn, e)
+			event.Account = eventAccount(conn, e)
+			c.pushEvent(event)
+		})
 	}
 
-	v.uidToConns[params.UID] = conn
-	go conn.Loop()
+	// Throttling happens before the timeout starts, as a busy fleet can take a while to get through.
+	if !v.throttle.wait(config.ConnectDelay, c.done) {
+		return errQuit
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), params.timeout())
+	defer cancel()
+
+	if err := connectContext(ctx, conn, server); err != nil {
+		return err
+	}
+
+	if err := waitForIRCConnection(ctx, reg, conn.ErrorChan()); err != nil {
+		conn.Disconnect()
+		return err
+	}
+
+	// SASL has finished by now, so we can take over its callbacks
+	if conn.UseSASL {
+		c.handleReauth(conn)
+	}
+
+	if !c.setIRC(conn) {
+		return errQuit
+	}
+	metricConnected.Inc()
+	c.pushVarysEvent(EventConnected, c.currentNick(), server, strings.Join(enabledCaps(conn), " "), c.currentHost())
+	c.releasePending()
+
+	go v.watchdog(c, conn, activity)
 	return nil
 }
 
+// quitMessage returns the message c should quit with, given the message asked for.
+// A blank message uses SetupParams.QuitMessage, and ${NICK} is replaced with c's nick.
+func (v *Varys) quitMessage(c *connection, message string) string {
+	if message == "" {
+		message = v.config().QuitMessage
+	}
+	return strings.ReplaceAll(message, "${NICK}", c.currentNick())
+}
+
 type QuitParams struct {
-	UID         string
+	UID string
+
+	// QuitMessage defaults to SetupParams.QuitMessage. ${NICK} is replaced with the connection's nick.
 	QuitMessage string
+
+	// Force quits straight away, dropping any messages still waiting to be sent
+	Force bool
 }
 
+// QuitIfConnected quits the connection for the UID, if there is one.
+//
+// Unless forced, messages that are still queued are sent first, waiting up to drainTimeout.
 func (v *Varys) QuitIfConnected(params QuitParams, _ *struct{}) error {
-	if conn, ok := v.uidToConns[params.UID]; ok {
-		if conn.Connected() {
-			conn.QuitMessage = params.QuitMessage
-			conn.Quit()
+	v.mu.Lock()
+	c, ok := v.uidToConns[params.UID]
+	delete(v.uidToConns, params.UID)
+	delete(v.idle, params.UID)
+	v.mu.Unlock()
+
+	if ok {
+		if !params.Force {
+			c.drain(drainTimeout)
 		}
+		c.quit(v.quitMessage(c, params.QuitMessage))
 	}
-	delete(v.uidToConns, params.UID)
 	return nil
 }
 
+// QuitAll quits every connection in parallel, and waits for them to close.
+//
+// Messages that are still queued are sent first, waiting up to drainTimeout.
+func (v *Varys) QuitAll(quitMessage string, _ *struct{}) error {
+	v.mu.Lock()
+	conns := v.uidToConns
+	v.uidToConns = make(map[string]*connection)
+	v.idle = make(map[string]*idleConnection)
+	v.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		wg.Add(1)
+		go func(c *connection) {
+			defer wg.Done()
+			c.drain(drainTimeout)
+			c.quit(v.quitMessage(c, quitMessage))
+			<-c.closed
+		}(c)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	// Allow connections that are busy reconnecting some extra time
+	case <-time.After(drainTimeout + 2*quitTimeout):
+		return errors.New("timed out waiting for connections to close")
+	}
+}
+
+// InterpolationParams controls which placeholders SendRaw replaces in each message.
+// Other placeholders are left as they are.
 type InterpolationParams struct {
-	Nick bool
+	Nick     bool // ${NICK} is replaced with the connection's nick
+	Account  bool // ${ACCOUNT} is replaced with our services account, or a blank string if we aren't logged in
+	RealName bool // ${REALNAME} is replaced with the connection's realname
+
+	// Channel, if set, replaces ${CHANNEL}
+	Channel string
+}
+
+// interpolate replaces the placeholders enabled by p in msg, for c.
+func (p InterpolationParams) interpolate(c *connection, msg string) string {
+	var replacements []string
+	if p.Nick {
+		replacements = append(replacements, "${NICK}", c.currentNick())
+	}
+	if p.Account {
+		replacements = append(replacements, "${ACCOUNT}", c.currentAccount())
+	}
+	if p.RealName {
+		var realName string
+		if conn := c.irc(); conn != nil {
+			realName = conn.RealName
+		}
+		replacements = append(replacements, "${REALNAME}", realName)
+	}
+	if p.Channel != "" {
+		replacements = append(replacements, "${CHANNEL}", p.Channel)
+	}
+
+	if len(replacements) == 0 {
+		return msg
+	}
+	return strings.NewReplacer(replacements...).Replace(msg)
 }
+
 type SendRawParams struct {
 	UID      string
 	Messages []string
 
 	Interpolation InterpolationParams
+
+	// Tags are IRCv3 message tags to send with each message, such as "+draft/reply".
+	// They are dropped if the server doesn't support message tags.
+	Tags map[string]string
+}
+
+// SendRawResult describes what SendRaw sent, totalled across every connection sent to
+type SendRawResult struct {
+	// Lines is how many lines were sent, or queued to be sent if rate limited
+	Lines int
+	// Bytes is the total length of those lines
+	Bytes int
+	// Duplicates is how many messages were dropped for repeating the previous message
+	Duplicates int
+	// Split is true if any message was too long, and was split across several lines
+	Split bool
 }
 
-func (v *Varys) SendRaw(params SendRawParams, _ *struct{}) error {
-	v.connCall(params.UID, func(c *irc.Connection) {
+func (r *SendRawResult) add(lines int, bytes int) {
+	r.Lines += lines
+	r.Bytes += bytes
+	r.Split = r.Split || lines > 1
+}
+
+func (v *Varys) SendRaw(params SendRawParams, result *SendRawResult) error {
+	if err := v.activate(params.UID); err != nil {
+		return err
+	}
+
+	config := v.config()
+
+	var sent SendRawResult
+	v.connCall(params.UID, func(c *connection) {
 		for _, msg := range params.Messages {
-			if params.Interpolation.Nick {
-				msg = strings.ReplaceAll(msg, "${NICK}", c.GetNick())
+			msg = params.Interpolation.interpolate(c, msg)
+			if config.DedupWindow > 0 && c.isDuplicate(msg, config.DedupWindow, !config.DedupAcrossTargets, time.Now()) {
+				sent.Duplicates++
+				continue
 			}
-			c.SendRaw(msg)
+			sent.add(c.sendSplit(msg, params.Tags))
+			metricMessagesSent.Inc()
 		}
 	})
+
+	if result != nil {
+		*result = sent
+	}
 	return nil
 }
 
 func (v *Varys) GetNick(uid string, result *string) error {
-	if conn, ok := v.uidToConns[uid]; ok {
-		*result = conn.GetNick()
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if c, ok := v.uidToConns[uid]; ok {
+		*result = c.currentNick()
 	}
 	return nil
 }
 
 func (v *Varys) Connected(uid string, result *bool) error {
-	if conn, ok := v.uidToConns[uid]; ok {
-		*result = conn.Connected()
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if c, ok := v.uidToConns[uid]; ok {
+		if conn := c.live(); conn != nil {
+			*result = conn.Connected()
+		}
 	}
 
 	return nil
@@ -185,8 +807,116 @@ type NickParams struct {
 }
 
 func (v *Varys) Nick(params NickParams, _ *struct{}) error {
-	if conn, ok := v.uidToConns[params.UID]; ok {
-		conn.Nick(params.Nick)
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if c, ok := v.uidToConns[params.UID]; ok {
+		// The server would reject a nick longer than it allows
+		nick := params.Nick
+		if max, ok := c.isupportInt("NICKLEN"); ok {
+			nick = truncate(nick, max)
+		}
+
+		c.mu.Lock()
+		c.nick = nick
+		c.nickAttempts = 0
+		c.mu.Unlock()
+
+		if conn := c.live(); conn != nil {
+			conn.Nick(nick)
+		}
 	}
 	return nil
 }
+
+// ConnectionInfo is a snapshot of the state of a single connection
+type ConnectionInfo struct {
+	// UID is how the connection is addressed, including its instance if it has one
+	UID       string
+	Instance  string
+	Nick      string
+	Username  string
+	Server    string
+	Connected bool
+	// TLS is whether the connection uses TLS, which is false after falling back to plaintext
+	TLS bool
+	// Transport is the transport the connection uses, TransportTLS or TransportPlaintext,
+	// or blank if it isn't connected
+	Transport string
+
+	// Host is the host the server shows us with, or blank if we don't know it yet
+	Host string
+	// RealHost is the host we had before the server cloaked us, or blank if we don't know it
+	RealHost string
+
+	// Latency is the most recently measured round-trip time to the server,
+	// or zero if it hasn't been measured since connecting.
+	Latency time.Duration
+
+	// Caps are the IRCv3 capabilities the server enabled, sorted by name
+	Caps []string
+
+	// ConnectedAt is when the server last welcomed us, which is reset by reconnecting
+	ConnectedAt time.Time
+
+	// ReconnectAttempts is how many times we have tried to reconnect, and Reconnects how many succeeded.
+	// They are reset by ResetReconnectCounts.
+	ReconnectAttempts int
+	Reconnects        int
+}
+
+// ResetReconnectCounts resets the reconnection counts in ListConnections.
+// A blank UID resets every connection.
+func (v *Varys) ResetReconnectCounts(uid string, _ *struct{}) error {
+	v.connCall(uid, func(c *connection) {
+		c.mu.Lock()
+		c.reconnectAttempts = 0
+		c.reconnects = 0
+		c.mu.Unlock()
+	})
+	return nil
+}
+
+// ListConnections returns the state of every connection, sorted by UID.
+func (v *Varys) ListConnections(_ struct{}, result *[]ConnectionInfo) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	infos := make([]ConnectionInfo, 0, len(v.uidToConns))
+	for uid, c := range v.uidToConns {
+		info := ConnectionInfo{
+			UID:      uid,
+			Instance: c.params.Instance,
+			Nick:     c.currentNick(),
+			Username: c.params.Username,
+			Server:   c.currentServer(),
+			Host:     c.currentHost(),
+			RealHost: c.currentRealHost(),
+			Caps:     c.currentCaps(),
+		}
+		if conn := c.live(); conn != nil {
+			info.Connected = conn.Connected()
+			info.TLS = conn.UseTLS
+			info.Transport = TransportPlaintext
+			if conn.UseTLS {
+				info.Transport = TransportTLS
+			}
+		}
+
+		c.mu.Lock()
+		info.Latency = c.latency
+		info.ConnectedAt = c.connectedAt
+		info.ReconnectAttempts = c.reconnectAttempts
+		info.Reconnects = c.reconnects
+		c.mu.Unlock()
+
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].UID < infos[j].UID
+	})
+
+	*result = infos
+	return nil
+}
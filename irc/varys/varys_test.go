@@ -0,0 +1,141 @@
+package varys
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuitMessage(t *testing.T) {
+	v := NewVarys(nil)
+	c := newConnection(ConnectParams{Nick: "qais~d"}, RateLimit{})
+	assert.Equal(t, "", v.quitMessage(c, ""))
+
+	v.connConfig.QuitMessage = "${NICK} left Discord"
+	assert.Equal(t, "qais~d left Discord", v.quitMessage(c, ""))
+	assert.Equal(t, "Offline for 5m, qais~d", v.quitMessage(c, "Offline for 5m, ${NICK}"))
+}
+
+func TestSendRawResult(t *testing.T) {
+	var r SendRawResult
+	r.add(1, 20)
+	assert.Equal(t, SendRawResult{Lines: 1, Bytes: 20}, r)
+
+	r.add(3, 1200)
+	assert.Equal(t, SendRawResult{Lines: 4, Bytes: 1220, Split: true}, r)
+
+	r.add(1, 5)
+	assert.True(t, r.Split, "split should stick once set")
+}
+
+func TestInterpolate(t *testing.T) {
+	c := newConnection(ConnectParams{Nick: "qais~d"}, RateLimit{})
+	c.account = "qais"
+
+	msg := "${NICK} ${ACCOUNT} ${CHANNEL} ${UNKNOWN}"
+	assert.Equal(t, msg, InterpolationParams{}.interpolate(c, msg))
+	assert.Equal(t, "qais~d qais #go-nuts ${UNKNOWN}",
+		InterpolationParams{Nick: true, Account: true, Channel: "#go-nuts"}.interpolate(c, msg))
+	assert.Equal(t, "qais~d ${ACCOUNT} ${CHANNEL} ${UNKNOWN}", InterpolationParams{Nick: true}.interpolate(c, msg))
+}
+
+func TestConnectParamsKey(t *testing.T) {
+	assert.Equal(t, "123", ConnectParams{UID: "123"}.key())
+	assert.Equal(t, "123/libera", ConnectParams{UID: "123", Instance: "libera"}.key())
+
+	v := NewVarys(nil)
+	v.add(newConnection(ConnectParams{UID: "123"}, RateLimit{}))
+	v.add(newConnection(ConnectParams{UID: "123", Instance: "libera"}, RateLimit{}))
+	assert.Len(t, v.uidToConns, 2, "instances should not replace each other")
+
+	existing := v.uidToConns["123"]
+	assert.ErrorIs(t, v.Connect(ConnectParams{UID: "123"}, nil), ErrAlreadyConnected)
+	assert.True(t, existing == v.uidToConns["123"], "connecting again should not replace the connection")
+
+	var count int
+	v.connCall("", func(*connection) { count++ })
+	assert.Equal(t, 2, count)
+}
+
+func TestSendBeforeWelcome(t *testing.T) {
+	v := NewVarys(nil)
+	c := newConnection(ConnectParams{UID: "123", Nick: "qais~d"}, RateLimit{})
+	v.add(c)
+
+	// Sending from a 001 callback happens before we have finished connecting
+	assert.NoError(t, v.SendRaw(SendRawParams{UID: "123", Messages: []string{"JOIN #go-nuts"}}, nil))
+	assert.Equal(t, []string{"JOIN #go-nuts"}, c.pending)
+
+	assert.True(t, c.setIRC(irc.IRC("qais~d", "qais")))
+
+	// Queue the released messages instead of writing them to a socket that isn't open
+	c.limiter = newTokenBucket(RateLimit{Messages: 1, Interval: time.Hour, Burst: 1})
+	c.releasePending()
+	assert.Empty(t, c.pending)
+	assert.Equal(t, []string{"JOIN #go-nuts"}, c.queue)
+}
+
+// TestConcurrentAccess is meant to be run with -race.
+func TestConcurrentAccess(t *testing.T) {
+	v := NewVarys(nil)
+	limit := RateLimit{Messages: 1000, Interval: time.Second, Burst: 1000}
+
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				fn(i)
+			}
+		}()
+	}
+
+	run(func(i int) {
+		assert.NoError(t, v.Setup(SetupParams{RateLimit: limit, QuitMessage: "bye"}, nil))
+	})
+	run(func(i int) {
+		uid := strconv.Itoa(i % 5)
+		v.add(newConnection(ConnectParams{UID: uid, Nick: "nick" + uid}, v.config().RateLimit))
+	})
+	run(func(i int) {
+		assert.NoError(t, v.QuitIfConnected(QuitParams{UID: strconv.Itoa(i % 5), Force: true}, nil))
+	})
+	run(func(i int) {
+		var result SendRawResult
+		assert.NoError(t, v.SendRaw(SendRawParams{Messages: []string{"PRIVMSG #channel :hello"}}, &result))
+	})
+	run(func(i int) {
+		var infos []ConnectionInfo
+		assert.NoError(t, v.ListConnections(struct{}{}, &infos))
+		var nicks map[string]string
+		assert.NoError(t, v.GetUIDToNicks(struct{}{}, &nicks))
+	})
+
+	wg.Wait()
+}
+
+func TestResetReconnectCounts(t *testing.T) {
+	v := NewVarys(nil)
+	c := newConnection(ConnectParams{UID: "123"}, RateLimit{})
+	c.reconnectAttempts, c.reconnects = 5, 2
+	v.add(c)
+
+	var infos []ConnectionInfo
+	assert.NoError(t, v.ListConnections(struct{}{}, &infos))
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, 5, infos[0].ReconnectAttempts)
+		assert.Equal(t, 2, infos[0].Reconnects)
+	}
+
+	assert.NoError(t, v.ResetReconnectCounts("", nil))
+	assert.NoError(t, v.ListConnections(struct{}{}, &infos))
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, 0, infos[0].ReconnectAttempts)
+		assert.Equal(t, 0, infos[0].Reconnects)
+	}
+}
@@ -0,0 +1,99 @@
+package varys
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// maxHostnameLength is the longest hostname allowed by DNS
+const maxHostnameLength = 253
+
+// defaultWebIRCGateway is the gateway name sent with WEBIRC
+const defaultWebIRCGateway = "discord"
+
+// webIRCInvalid are the characters that can't appear in the gateway name or flags,
+// because they would end the parameter or the line
+const webIRCInvalid = " \r\n\x00"
+
+// WebIRC spoofs the hostname and IP address a connection appears to come from.
+// It is sent with SetupParams.WebIRCPassword, using the WEBIRC command.
+type WebIRC struct {
+	// Gateway is the name of the gateway. Defaults to "discord".
+	Gateway string
+
+	Hostname string
+	IP       string
+
+	// Flags are optional WEBIRC flags, such as "secure". Flags without a value are sent as just their name.
+	Flags map[string]string
+}
+
+func (w WebIRC) empty() bool {
+	return w.Hostname == "" && w.IP == ""
+}
+
+// params returns the parameters of the WEBIRC command, after the password,
+// or an error if any of them are malformed.
+func (w WebIRC) params() (string, error) {
+	ip := net.ParseIP(w.IP)
+	if ip == nil {
+		return "", fmt.Errorf("webirc: %q is not an ip address", w.IP)
+	}
+	if !validHostname(w.Hostname) {
+		return "", fmt.Errorf("webirc: %q is not a valid hostname", w.Hostname)
+	}
+
+	gateway := w.Gateway
+	if gateway == "" {
+		gateway = defaultWebIRCGateway
+	}
+	if strings.HasPrefix(gateway, ":") || strings.ContainsAny(gateway, webIRCInvalid) {
+		return "", fmt.Errorf("webirc: %q is not a valid gateway name", gateway)
+	}
+
+	// IPv6 addresses can start with a colon, which would make them the trailing parameter
+	addr := ip.String()
+	if strings.HasPrefix(addr, ":") {
+		addr = "0" + addr
+	}
+
+	params := gateway + " " + w.Hostname + " " + addr
+	if len(w.Flags) == 0 {
+		return params, nil
+	}
+
+	flags := make([]string, 0, len(w.Flags))
+	for flag, value := range w.Flags {
+		if flag == "" || strings.ContainsAny(flag, webIRCInvalid+"=") || strings.ContainsAny(value, webIRCInvalid) {
+			return "", fmt.Errorf("webirc: %q=%q is not a valid flag", flag, value)
+		}
+		if value != "" {
+			flag += "=" + value
+		}
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	return params + " :" + strings.Join(flags, " "), nil
+}
+
+// validHostname returns true if host looks like a hostname: dot separated labels
+// of letters, digits and hyphens.
+func validHostname(host string) bool {
+	if host == "" || len(host) > maxHostnameLength {
+		return false
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > maxHostLength || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
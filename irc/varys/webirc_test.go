@@ -0,0 +1,54 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebIRCParams(t *testing.T) {
+	params, err := WebIRC{Hostname: "1234.user.discord", IP: "10.0.0.1"}.params()
+	assert.NoError(t, err)
+	assert.Equal(t, "discord 1234.user.discord 10.0.0.1", params)
+
+	params, err = WebIRC{
+		Gateway:  "bridge",
+		Hostname: "1234.user.discord",
+		IP:       "::1",
+		Flags:    map[string]string{"secure": "", "local-port": "6697"},
+	}.params()
+	assert.NoError(t, err)
+	assert.Equal(t, "bridge 1234.user.discord 0::1 :local-port=6697 secure", params)
+
+	_, err = WebIRC{Hostname: "1234.user.discord", IP: "10.0.0"}.params()
+	assert.Error(t, err)
+
+	_, err = WebIRC{Hostname: "bad host.discord", IP: "10.0.0.1"}.params()
+	assert.Error(t, err)
+
+	for _, w := range []WebIRC{
+		{Gateway: "bad gateway"},
+		{Gateway: "bad\r\nQUIT"},
+		{Gateway: ":bad"},
+		{Flags: map[string]string{"secure\r\nQUIT": ""}},
+		{Flags: map[string]string{"a b": ""}},
+		{Flags: map[string]string{"a=b": ""}},
+		{Flags: map[string]string{"": "6697"}},
+		{Flags: map[string]string{"local-port": "66 97"}},
+		{Flags: map[string]string{"local-port": "6697\n"}},
+	} {
+		w.Hostname = "1234.user.discord"
+		w.IP = "10.0.0.1"
+		_, err = w.params()
+		assert.Error(t, err, w)
+	}
+}
+
+func TestValidHostname(t *testing.T) {
+	assert.True(t, validHostname("1234.bot.discord"))
+	assert.True(t, validHostname("localhost"))
+	assert.False(t, validHostname(""))
+	assert.False(t, validHostname("a..b"))
+	assert.False(t, validHostname("-a.discord"))
+	assert.False(t, validHostname("a_b.discord"))
+}
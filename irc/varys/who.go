@@ -0,0 +1,206 @@
+package varys
+
+import (
+	"strconv"
+	"strings"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// whoxFields are the WHOX fields we ask for: the token, channel, user, host, server, nick, flags, account and realname.
+// Replies list them in this order.
+const whoxFields = "tcuhsnfar"
+
+// WhoRow is a user matched by WHO
+type WhoRow struct {
+	Nick     string
+	User     string
+	Host     string
+	Server   string
+	RealName string
+
+	// Channel is a channel the user is in, or "*" if the server didn't pick one
+	Channel string
+	// Flags are "H" (here) or "G" (gone, i.e. away), followed by flags such as "*" for IRC operators and "@" for channel operators
+	Flags string
+	// Account is the user's services account. It's only known if the server supports WHOX.
+	Account string
+}
+
+// whoRequest is a WHO we are waiting for replies to
+type whoRequest struct {
+	mask string // lowercased
+	// token tags WHOX replies, or is blank if the server doesn't support WHOX
+	token string
+}
+
+type whoRows struct {
+	rows []WhoRow
+}
+
+// startWho records that we are sending a WHO for mask, and returns the command to send.
+// WHOX is used if the server supports it, so that we also learn users' accounts.
+func (c *connection) startWho(mask string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	req := whoRequest{mask: strings.ToLower(mask)}
+	command := "WHO " + mask
+	if c.supports("WHOX") {
+		// Tokens are at most three digits
+		c.whoxToken = (c.whoxToken + 1) % 1000
+		req.token = strconv.Itoa(c.whoxToken)
+		command += " %" + whoxFields + "," + req.token
+	}
+	c.whoQueue = append(c.whoQueue, req)
+	return command
+}
+
+// whoFor returns the mask of the WHO that a reply with token belongs to.
+// Replies without a token belong to the oldest WHO without one, as servers answer in order.
+func (c *connection) whoFor(token string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, req := range c.whoQueue {
+		if req.token == token {
+			return req.mask, true
+		}
+	}
+	return "", false
+}
+
+// finishWho forgets the WHO for mask.
+func (c *connection) finishWho(mask string) {
+	mask = strings.ToLower(mask)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, req := range c.whoQueue {
+		if req.mask == mask {
+			c.whoQueue = append(c.whoQueue[:i:i], c.whoQueue[i+1:]...)
+			return
+		}
+	}
+}
+
+// parseWhoReply parses RPL_WHOREPLY.
+//
+// 352 <me> <channel> <user> <host> <server> <nick> <flags> :<hopcount> <realname>
+func parseWhoReply(args []string) (WhoRow, bool) {
+	if len(args) < 8 {
+		return WhoRow{}, false
+	}
+
+	var realName string
+	if i := strings.IndexByte(args[7], ' '); i != -1 {
+		realName = args[7][i+1:]
+	}
+	return WhoRow{
+		Channel:  args[1],
+		User:     args[2],
+		Host:     args[3],
+		Server:   args[4],
+		Nick:     args[5],
+		Flags:    args[6],
+		RealName: realName,
+	}, true
+}
+
+// parseWhoxReply parses RPL_WHOSPCRPL with our whoxFields, returning the row and its token.
+//
+// 354 <me> <token> <channel> <user> <host> <server> <nick> <flags> <account> :<realname>
+func parseWhoxReply(args []string) (WhoRow, string, bool) {
+	if len(args) < 10 {
+		return WhoRow{}, "", false
+	}
+
+	row := WhoRow{
+		Channel:  args[2],
+		User:     args[3],
+		Host:     args[4],
+		Server:   args[5],
+		Nick:     args[6],
+		Flags:    args[7],
+		Account:  args[8],
+		RealName: args[9],
+	}
+	// Users who aren't logged in have the account "0"
+	if row.Account == "0" {
+		row.Account = ""
+	}
+	return row, args[1], true
+}
+
+// handleWho collects WHO and WHOX replies into the pending who queries.
+func (c *connection) handleWho(conn *irc.Connection) {
+	add := func(mask string, row WhoRow) {
+		c.queries.update(queryKey("who", mask), func(value interface{}) {
+			w := value.(*whoRows)
+			w.rows = append(w.rows, row)
+		})
+	}
+
+	conn.AddCallback("352", func(e *irc.Event) {
+		row, ok := parseWhoReply(e.Arguments)
+		if !ok {
+			return
+		}
+		if mask, ok := c.whoFor(""); ok {
+			add(mask, row)
+		}
+	})
+
+	conn.AddCallback("354", func(e *irc.Event) {
+		row, token, ok := parseWhoxReply(e.Arguments)
+		if !ok {
+			return
+		}
+		if mask, ok := c.whoFor(token); ok {
+			add(mask, row)
+		}
+	})
+
+	// RPL_ENDOFWHO <me> <mask> :End of /WHO list
+	conn.AddCallback("315", func(e *irc.Event) {
+		if len(e.Arguments) < 2 {
+			return
+		}
+		c.finishWho(e.Arguments[1])
+		c.queries.finish(queryKey("who", e.Arguments[1]), nil)
+	})
+}
+
+type WhoParams struct {
+	UID  string
+	Mask string
+}
+
+// Who lists the users matching a mask, such as a channel or a hostmask. If the UID is blank, any connection is used.
+//
+// If the server supports WHOX, users' accounts are included.
+func (v *Varys) Who(params WhoParams, result *[]WhoRow) error {
+	if err := validTarget(params.Mask); err != nil {
+		return err
+	}
+
+	c, err := v.queryConn(params.UID)
+	if err != nil {
+		return err
+	}
+
+	key := queryKey("who", params.Mask)
+	wait := c.queries.start(key, &whoRows{}, func() {
+		c.send(c.startWho(params.Mask))
+	})
+
+	value, err := c.queries.wait(key, wait, queryTimeout)
+	if err != nil {
+		c.finishWho(params.Mask)
+		return err
+	}
+
+	*result = value.(*whoRows).rows
+	return nil
+}
@@ -0,0 +1,50 @@
+package varys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWhoReply(t *testing.T) {
+	row, ok := parseWhoReply([]string{"me", "#go-nuts", "~qais", "example.com", "irc.example.net", "qaisjp", "H@", "0 Qais Patankar"})
+	assert.True(t, ok)
+	assert.Equal(t, WhoRow{
+		Nick: "qaisjp", User: "~qais", Host: "example.com", Server: "irc.example.net",
+		RealName: "Qais Patankar", Channel: "#go-nuts", Flags: "H@",
+	}, row)
+
+	_, ok = parseWhoReply([]string{"me", "#go-nuts"})
+	assert.False(t, ok)
+}
+
+func TestParseWhoxReply(t *testing.T) {
+	row, token, ok := parseWhoxReply([]string{"me", "7", "#go-nuts", "~qais", "example.com", "irc.example.net", "qaisjp", "G", "qais", "Qais Patankar"})
+	assert.True(t, ok)
+	assert.Equal(t, "7", token)
+	assert.Equal(t, "qais", row.Account)
+	assert.Equal(t, "Qais Patankar", row.RealName)
+
+	row, _, _ = parseWhoxReply([]string{"me", "7", "#go-nuts", "~qais", "example.com", "irc.example.net", "qaisjp", "G", "0", "Qais Patankar"})
+	assert.Equal(t, "", row.Account, "0 means not logged in")
+}
+
+func TestStartWho(t *testing.T) {
+	c := newConnection(ConnectParams{}, RateLimit{})
+	assert.Equal(t, "WHO #Go-Nuts", c.startWho("#Go-Nuts"))
+
+	c.isupport = map[string]string{"WHOX": ""}
+	assert.Equal(t, "WHO #general %tcuhsnfar,1", c.startWho("#general"))
+
+	mask, ok := c.whoFor("")
+	assert.True(t, ok)
+	assert.Equal(t, "#go-nuts", mask)
+	mask, ok = c.whoFor("1")
+	assert.True(t, ok)
+	assert.Equal(t, "#general", mask)
+
+	c.finishWho("#go-nuts")
+	_, ok = c.whoFor("")
+	assert.False(t, ok)
+	assert.Len(t, c.whoQueue, 1)
+}
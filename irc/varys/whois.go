@@ -0,0 +1,148 @@
+package varys
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	irc "github.com/qaisjp/go-ircevent"
+)
+
+// WhoisResult is what the server told us about a user
+type WhoisResult struct {
+	Nick     string
+	User     string
+	Host     string
+	RealName string
+
+	Server     string
+	ServerInfo string
+
+	Account  string
+	Channels []string
+
+	Idle   time.Duration
+	SignOn time.Time
+}
+
+type whois struct {
+	result   WhoisResult
+	notFound bool
+}
+
+// handleWhois collects WHOIS replies into the pending whois queries.
+func (c *connection) handleWhois(conn *irc.Connection) {
+	// All WHOIS numerics start with: <me> <nick> ...
+	update := func(e *irc.Event, fn func(w *whois, args []string)) {
+		if len(e.Arguments) < 2 {
+			return
+		}
+		c.queries.update(queryKey("whois", e.Arguments[1]), func(value interface{}) {
+			fn(value.(*whois), e.Arguments)
+		})
+	}
+
+	// RPL_WHOISUSER <me> <nick> <user> <host> * :<realname>
+	conn.AddCallback("311", func(e *irc.Event) {
+		update(e, func(w *whois, args []string) {
+			if len(args) >= 6 {
+				w.result.Nick, w.result.User, w.result.Host, w.result.RealName = args[1], args[2], args[3], args[5]
+			}
+		})
+	})
+
+	// RPL_WHOISSERVER <me> <nick> <server> :<info>
+	conn.AddCallback("312", func(e *irc.Event) {
+		update(e, func(w *whois, args []string) {
+			if len(args) >= 4 {
+				w.result.Server, w.result.ServerInfo = args[2], args[3]
+			}
+		})
+	})
+
+	// RPL_WHOISIDLE <me> <nick> <idle> <signon> :seconds idle, signon time
+	conn.AddCallback("317", func(e *irc.Event) {
+		update(e, func(w *whois, args []string) {
+			if len(args) >= 3 {
+				if idle, err := strconv.Atoi(args[2]); err == nil {
+					w.result.Idle = time.Duration(idle) * time.Second
+				}
+			}
+			if len(args) >= 5 {
+				if signOn, err := strconv.ParseInt(args[3], 10, 64); err == nil {
+					w.result.SignOn = time.Unix(signOn, 0)
+				}
+			}
+		})
+	})
+
+	// RPL_WHOISCHANNELS <me> <nick> :{[@+]<channel>}
+	conn.AddCallback("319", func(e *irc.Event) {
+		update(e, func(w *whois, args []string) {
+			w.result.Channels = append(w.result.Channels, strings.Fields(args[len(args)-1])...)
+		})
+	})
+
+	// RPL_WHOISACCOUNT <me> <nick> <account> :is logged in as
+	conn.AddCallback("330", func(e *irc.Event) {
+		update(e, func(w *whois, args []string) {
+			if len(args) >= 3 {
+				w.result.Account = args[2]
+			}
+		})
+	})
+
+	// ERR_NOSUCHNICK <me> <nick> :No such nick/channel
+	conn.AddCallback("401", func(e *irc.Event) {
+		update(e, func(w *whois, args []string) {
+			w.notFound = true
+		})
+	})
+
+	// RPL_ENDOFWHOIS <me> <nick> :End of /WHOIS list
+	conn.AddCallback("318", func(e *irc.Event) {
+		if len(e.Arguments) < 2 {
+			return
+		}
+
+		key := queryKey("whois", e.Arguments[1])
+		var err error
+		c.queries.update(key, func(value interface{}) {
+			if value.(*whois).notFound {
+				err = errors.New("no such nick: " + e.Arguments[1])
+			}
+		})
+		c.queries.finish(key, err)
+	})
+}
+
+type WhoisParams struct {
+	UID    string
+	Target string
+}
+
+// Whois looks up a user. If the UID is blank, any connection is used.
+func (v *Varys) Whois(params WhoisParams, result *WhoisResult) error {
+	if err := validTarget(params.Target); err != nil {
+		return err
+	}
+
+	c, err := v.queryConn(params.UID)
+	if err != nil {
+		return err
+	}
+
+	key := queryKey("whois", params.Target)
+	wait := c.queries.start(key, &whois{}, func() {
+		c.send("WHOIS " + params.Target)
+	})
+
+	value, err := c.queries.wait(key, wait, queryTimeout)
+	if err != nil {
+		return err
+	}
+
+	*result = value.(*whois).result
+	return nil
+}
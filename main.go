@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -13,6 +14,7 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/gobwas/glob"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/qaisjp/go-discord-irc/bridge"
 	ircnick "github.com/qaisjp/go-discord-irc/irc/nick"
 	log "github.com/sirupsen/logrus"
@@ -25,6 +27,7 @@ func main() {
 	debugMode := flag.Bool("debug", false, "Debug mode? (false = use value from settings)")
 	notls := flag.Bool("no-tls", false, "Avoids using TLS att all when connecting to IRC server ")
 	insecure := flag.Bool("insecure", false, "Skip TLS certificate verification? (INSECURE MODE) (false = use value from settings)")
+	metricsAddr := flag.String("metrics", "", "Address to serve metrics on at /metrics, e.g. localhost:9100 (blank = disabled)")
 
 	// Secret devmode
 	devMode := flag.Bool("dev", false, "")
@@ -42,6 +45,14 @@ func main() {
 		log.Println("Running in simple mode.")
 	}
 
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		go func() {
+			log.Fatalln(errors.Wrap(http.ListenAndServe(*metricsAddr, mux), "could not serve metrics"))
+		}()
+	}
+
 	viper := viper.New()
 	ext := filepath.Ext(*config)
 	configName := strings.TrimSuffix(filepath.Base(*config), ext)